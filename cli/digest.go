@@ -0,0 +1,169 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/digest"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+// digestCmd collects the best scored unread entries of the current edition of
+// each category, the entries the service kept, and delivers them with a short
+// summary of the extracted article.
+//
+// The summarizer and the delivery are configured in the config file:
+// ollama_url, ollama_model, digest_dir and smtp_addr, smtp_from, smtp_to,
+// smtp_username, smtp_password.
+func (a *app) digest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	top := fs.Int("top", 5, "number of entries per category")
+	categories := fs.String("categories", fmt.Sprintf("%d,%d", domain.CatNewsAggregator, domain.CatSmallWeb), "comma separated category ids")
+	summarizer := fs.String("summarizer", "ollama", "summarizer: ollama or fake")
+	deliver := fs.String("deliver", "stdout", "delivery: stdout, dir or smtp")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	catIDs := make([]int64, 0)
+	for _, s := range strings.Split(*categories, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid category %q: %v", s, err)
+		}
+		catIDs = append(catIDs, id)
+	}
+
+	var s digest.Summarizer
+	switch *summarizer {
+	case "ollama":
+		url, model := a.conf["ollama_url"], a.conf["ollama_model"]
+		if url == "" {
+			url = "http://localhost:11434"
+		}
+		if model == "" {
+			return fmt.Errorf("ollama_model not set in config")
+		}
+		s = digest.NewOllama(url, model)
+	case "fake":
+		s = digest.Fake{}
+	default:
+		return fmt.Errorf("unknown summarizer %q, choose from ollama, fake", *summarizer)
+	}
+
+	var d digest.Deliverer
+	switch *deliver {
+	case "stdout":
+	case "dir":
+		if a.conf["digest_dir"] == "" {
+			return fmt.Errorf("digest_dir not set in config")
+		}
+		d = digest.Dir{Path: a.conf["digest_dir"]}
+	case "smtp":
+		to := strings.Fields(strings.ReplaceAll(a.conf["smtp_to"], ",", " "))
+		if a.conf["smtp_addr"] == "" || a.conf["smtp_from"] == "" || len(to) == 0 {
+			return fmt.Errorf("smtp_addr, smtp_from and smtp_to must be set in config")
+		}
+		d = digest.SMTP{
+			Addr:     a.conf["smtp_addr"],
+			From:     a.conf["smtp_from"],
+			To:       to,
+			Username: a.conf["smtp_username"],
+			Password: a.conf["smtp_password"],
+		}
+	default:
+		return fmt.Errorf("unknown delivery %q, choose from stdout, dir, smtp", *deliver)
+	}
+
+	dg, err := a.buildDigest(catIDs, *top, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := dg.Summarize(context.Background(), s); err != nil {
+		return err
+	}
+	if d == nil {
+		fmt.Print(dg.Markdown())
+		return nil
+	}
+
+	return d.Deliver(dg)
+}
+
+func (a *app) buildDigest(catIDs []int64, top int, now time.Time) (digest.Digest, error) {
+	pqClient, err := a.connect()
+	if err != nil {
+		return digest.Digest{}, err
+	}
+	tuiRepo := storage.NewTuiRepo(pqClient.DB())
+	rated, err := tuiRepo.RatedEntries()
+	if err != nil {
+		return digest.Digest{}, fmt.Errorf("could not get rated entries: %v", err)
+	}
+	stored, err := tuiRepo.Rules()
+	if err != nil {
+		return digest.Digest{}, fmt.Errorf("could not get accepted rules: %v", err)
+	}
+	scorer := scoring.Scorer{Model: scoring.Train(rated), Rules: scoring.DefaultRules.With(stored)}
+
+	repo := storage.NewCliRepo(pqClient.DB())
+	catNames, err := repo.CategoryNames()
+	if err != nil {
+		return digest.Digest{}, fmt.Errorf("could not get category names: %v", err)
+	}
+	feedNames, err := repo.FeedNames()
+	if err != nil {
+		return digest.Digest{}, fmt.Errorf("could not get feed names: %v", err)
+	}
+
+	mf := a.miniflux()
+	dg := digest.Digest{Date: now}
+	for _, catID := range catIDs {
+		entries, err := mf.Unread(catID)
+		if err != nil {
+			return digest.Digest{}, fmt.Errorf("could not fetch unread entries: %v", err)
+		}
+		if entries, err = tuiRepo.WithExtracted(entries); err != nil {
+			return digest.Digest{}, fmt.Errorf("could not get extracted content: %v", err)
+		}
+		edition, err := tuiRepo.CurrentEdition(catID)
+		if err != nil {
+			return digest.Digest{}, fmt.Errorf("could not get current edition: %v", err)
+		}
+		if edition != nil {
+			entries = slices.DeleteFunc(entries, func(e domain.Entry) bool {
+				return !slices.Contains(edition.EntryIDs, e.ID)
+			})
+		}
+		items := make([]digest.Item, 0, len(entries))
+		for _, e := range entries {
+			exp := scorer.Score(e, catID, now)
+			if exp.Skip {
+				continue
+			}
+			items = append(items, digest.Item{Entry: e, Feed: feedNames[e.FeedID], Score: exp.Score})
+		}
+		slices.SortStableFunc(items, func(a, b digest.Item) int {
+			return cmp.Compare(b.Score, a.Score)
+		})
+
+		title := catNames[catID]
+		if title == "" {
+			title = fmt.Sprintf("Category %d", catID)
+		}
+		dg.Sections = append(dg.Sections, digest.Section{
+			Title: title,
+			Items: items[:min(top, len(items))],
+		})
+	}
+
+	return dg, nil
+}
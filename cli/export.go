@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+// export writes the rated entries as a labelled dataset. With -split the
+// entries are divided into a train and a test set with the same mix of
+// ratings, written as two files in the -out directory.
+func (a *app) export(args []string) error {
+	fs, format := newFlagSet("export")
+	withContent := fs.Bool("content", false, "include the content of the entries")
+	truncate := fs.Int("truncate", 0, "truncate the content to this many characters, 0 for no limit")
+	before := fs.String("before", "", "only entries rated before this date, yyyy-mm-dd")
+	split := fs.Float64("split", 0, "share of each rating to put in the test set, 0 for no split")
+	seed := fs.Uint64("seed", 1, "seed for the random split")
+	out := fs.String("out", ".", "directory for the train and test files when splitting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *split < 0 || *split >= 1 {
+		return fmt.Errorf("split must be at least 0 and less than 1")
+	}
+	var cutoff time.Time
+	if *before != "" {
+		var err error
+		if cutoff, err = time.ParseInLocation(time.DateOnly, *before, time.Local); err != nil {
+			return fmt.Errorf("invalid date: %v", err)
+		}
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	entries, err := repo.LabelledEntries(cutoff)
+	if err != nil {
+		return err
+	}
+
+	toReport := func(entries []storage.LabelledEntry) Report {
+		report := Report{Columns: []string{"id", "feed_id", "feed", "category_id", "category", "rating", "updated", "title", "url"}}
+		if *withContent {
+			report.Columns = append(report.Columns, "content")
+		}
+		for _, e := range entries {
+			row := []any{e.ID, e.FeedID, e.FeedTitle, e.CategoryID, e.CategoryTitle, e.Rating, e.Updated, e.Title, e.URL}
+			if *withContent {
+				content := []rune(e.Content)
+				if *truncate > 0 && len(content) > *truncate {
+					content = content[:*truncate]
+				}
+				row = append(row, string(content))
+			}
+			report.Add(row...)
+		}
+		return report
+	}
+
+	if *split == 0 {
+		return toReport(entries).Write(os.Stdout, *format)
+	}
+
+	train, test := stratifiedSplit(entries, *split, *seed)
+	for name, set := range map[string][]storage.LabelledEntry{"train": train, "test": test} {
+		path := filepath.Join(*out, fmt.Sprintf("%s.%s", name, *format))
+		if err := writeReportFile(path, toReport(set), *format); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %d entries to %s\n", len(set), path)
+	}
+
+	return nil
+}
+
+// stratifiedSplit puts a share of the entries of every rating in the test
+// set. The same seed gives the same split. Both sets keep the original order.
+func stratifiedSplit(entries []storage.LabelledEntry, share float64, seed uint64) ([]storage.LabelledEntry, []storage.LabelledEntry) {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	byRating := make(map[string][]int)
+	ratings := make([]string, 0)
+	for i, e := range entries {
+		if _, ok := byRating[e.Rating]; !ok {
+			ratings = append(ratings, e.Rating)
+		}
+		byRating[e.Rating] = append(byRating[e.Rating], i)
+	}
+	slices.Sort(ratings)
+
+	inTest := make(map[int]bool)
+	for _, rating := range ratings {
+		idxs := byRating[rating]
+		rnd.Shuffle(len(idxs), func(i, j int) { idxs[i], idxs[j] = idxs[j], idxs[i] })
+		n := int(math.Round(float64(len(idxs)) * share))
+		for _, idx := range idxs[:n] {
+			inTest[idx] = true
+		}
+	}
+
+	train := make([]storage.LabelledEntry, 0, len(entries)-len(inTest))
+	test := make([]storage.LabelledEntry, 0, len(inTest))
+	for i, e := range entries {
+		if inTest[i] {
+			test = append(test, e)
+		} else {
+			train = append(train, e)
+		}
+	}
+
+	return train, test
+}
+
+func writeReportFile(path string, report Report, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	if err := report.Write(f, format); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
+)
+
+// extract prints the article the readability extractor finds on the pages,
+// to check what the service would use for entries with little content.
+func (a *app) extract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 15*time.Second, "timeout per page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no urls to extract")
+	}
+
+	r := source.NewReadability(*timeout)
+	for _, link := range fs.Args() {
+		content, err := r.Extract(domain.Entry{URL: link})
+		if err != nil {
+			return fmt.Errorf("could not extract %s: %v", link, err)
+		}
+		fmt.Printf("# %s\n\n%s\n\n", link, content)
+	}
+
+	return nil
+}
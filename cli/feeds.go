@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+// z value for a 95% confidence interval
+const wilsonZ = 1.96
+
+// feeds reports the quality of every feed that has rated entries. With
+// -suggest-unsubscribe only the feeds are listed of which we can be fairly
+// sure that the finish rate is below the threshold, i.e. the upper bound of
+// the Wilson interval is below it.
+func (a *app) feeds(args []string) error {
+	fs, format := newFlagSet("feeds")
+	sortBy := fs.String("sort", "wilson", "column to sort on")
+	asc := fs.Bool("asc", false, "sort ascending instead of descending")
+	suggest := fs.Float64("suggest-unsubscribe", 0, "only list feeds with a finish rate that is very likely below this threshold")
+	minRated := fs.Int64("min-rated", 5, "minimum number of rated entries for -suggest-unsubscribe")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *suggest < 0 || *suggest > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1")
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	stats, err := repo.FeedStats()
+	if err != nil {
+		return err
+	}
+	names, err := repo.CategoryNames()
+	if err != nil {
+		return err
+	}
+	mf := a.miniflux()
+	lastEntry := make(map[int64]time.Time, len(stats))
+	for _, s := range stats {
+		if lastEntry[s.FeedID], err = mf.LastPublished(s.FeedID); err != nil {
+			return fmt.Errorf("could not get last entry of feed %d: %v", s.FeedID, err)
+		}
+	}
+
+	report := FeedReport(stats, names, lastEntry)
+	if *suggest > 0 {
+		flagged := make([][]any, 0)
+		for i, s := range stats {
+			_, upper := wilson(s.Finished, s.Rated)
+			if s.Rated >= *minRated && upper < *suggest {
+				flagged = append(flagged, report.Rows[i])
+			}
+		}
+		report.Rows = flagged
+	}
+	if err := report.Sort(*sortBy, !*asc); err != nil {
+		return err
+	}
+
+	return report.Write(os.Stdout, *format)
+}
+
+// FeedReport lists the stats per feed. The last entry is the newest entry
+// of the feed in Miniflux, whether it was rated or not.
+func FeedReport(stats []storage.FeedStat, names map[int64]string, lastEntry map[int64]time.Time) Report {
+	report := Report{Columns: []string{"feed_id", "feed", "category", "rated", "finish_rate", "not_opened_rate", "wilson", "last_rated", "last_entry"}}
+	for _, s := range stats {
+		lower, _ := wilson(s.Finished, s.Rated)
+		report.Add(s.FeedID, s.Title, names[s.CategoryID], s.Rated,
+			rate(s.Finished, s.Rated), rate(s.NotOpened, s.Rated), lower, s.LastRated, lastEntry[s.FeedID])
+	}
+
+	return report
+}
+
+func rate(count, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// wilson returns the bounds of the Wilson score interval for the share of
+// successes. Unlike the plain rate, the lower bound ranks a feed with 3 out
+// of 3 finished below one with 80 out of 100.
+func wilson(successes, total int64) (float64, float64) {
+	if total == 0 {
+		return 0, 1
+	}
+	n := float64(total)
+	p := float64(successes) / n
+	z2 := wilsonZ * wilsonZ
+	center := p + z2/(2*n)
+	margin := wilsonZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	denom := 1 + z2/n
+
+	return (center - margin) / denom, (center + margin) / denom
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+const importPageSize = 100
+
+// importHistory adds the entries that were read in Miniflux as ratings.
+// Starred entries count as finished, the others get the weak_read label.
+// Entries that are already rated are skipped, so running it twice is safe.
+func (a *app) importHistory(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	categoryID := fs.Int64("category", 0, "only import this category, 0 for all")
+	before := fs.String("before", "", "only entries published before this date, yyyy-mm-dd, default the first rating")
+	dryRun := fs.Bool("dry-run", false, "only count the entries, do not store them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mf := a.miniflux()
+	pqClient, err := a.connect()
+	if err != nil {
+		return err
+	}
+	if err := mf.SyncTo(storage.NewTuiRepo(pqClient.DB())); err != nil {
+		return err
+	}
+	repo := storage.NewCliRepo(pqClient.DB())
+
+	// Entries read after the first rating were read, or marked read by the
+	// service, while the project was in use. They are not history.
+	var cutoff time.Time
+	switch {
+	case *before != "":
+		if cutoff, err = time.ParseInLocation(time.DateOnly, *before, time.Local); err != nil {
+			return fmt.Errorf("invalid date: %v", err)
+		}
+	default:
+		if cutoff, err = repo.FirstRated(); err != nil {
+			return fmt.Errorf("could not get first rating: %v", err)
+		}
+		if cutoff.IsZero() {
+			return fmt.Errorf("no ratings yet, give a date with -before")
+		}
+	}
+
+	cats, err := mf.Categories()
+	if err != nil {
+		return fmt.Errorf("could not fetch miniflux categories: %v", err)
+	}
+	for _, cat := range cats {
+		if *categoryID != 0 && cat.ID != *categoryID {
+			continue
+		}
+		var fetched, added int64
+		for offset := 0; ; offset += importPageSize {
+			page, total, err := mf.ReadEntries(cat.ID, cutoff, offset, importPageSize)
+			if err != nil {
+				return fmt.Errorf("could not fetch entries for category %d: %v", cat.ID, err)
+			}
+			fetched += int64(len(page))
+			if !*dryRun {
+				n, err := repo.ImportEntries(weakLabels(page))
+				if err != nil {
+					return err
+				}
+				added += n
+			}
+			if len(page) == 0 || offset+len(page) >= total {
+				break
+			}
+		}
+		fmt.Printf("%s: %d read entries, %d added\n", cat.Title, fetched, added)
+	}
+
+	return nil
+}
+
+func weakLabels(entries []source.ReadEntry) []domain.RatedEntry {
+	rated := make([]domain.RatedEntry, 0, len(entries))
+	for _, e := range entries {
+		rating := "weak_read"
+		if e.Starred {
+			rating = "finished"
+		}
+		rated = append(rated, domain.RatedEntry{
+			Entry:   e.Entry,
+			Rating:  rating,
+			Updated: e.Changed,
+		})
+	}
+
+	return rated
+}
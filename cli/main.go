@@ -1,41 +1,149 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
 	"go-mod.ewintr.nl/algorithmic-rss/storage"
 )
 
+type command struct {
+	usage string
+	run   func(a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"summary": {usage: "category × rating matrix of all rated entries", run: (*app).summary},
+	"feeds":   {usage: "quality report per feed", run: (*app).feeds},
+	"ratings": {usage: "number of entries per rating", run: (*app).ratings},
+	"trends":  {usage: "ratings over time per category or feed", run: (*app).trends},
+	"export":  {usage: "rated entries as a labelled dataset", run: (*app).export},
+	"migrate": {usage: "apply database migrations", run: (*app).migrate},
+	"import":  {usage: "import the read history from miniflux as weak labels", run: (*app).importHistory},
+	"rules":   {usage: "work with the rule set, see rules -h", run: (*app).rules},
+	"opml":    {usage: "export or import the feeds as opml, see opml -h", run: (*app).opml},
+	"digest":  {usage: "summaries of the best unread entries per category", run: (*app).digest},
+	"extract": {usage: "the article content found on web pages", run: (*app).extract},
+}
+
+// app holds what the commands share. The database connection is only made
+// when a command asks for it.
+type app struct {
+	conf   map[string]string
+	client *storage.Client
+}
+
 func main() {
 	configPath := flag.String("config", "/home/erik/.config/algorithmicrss/tui.toml", "path to config file")
+	flag.Usage = usage
 	flag.Parse()
 
+	name := "summary"
+	args := flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Printf("unknown command: %s\n\n", name)
+		usage()
+		os.Exit(1)
+	}
+
 	conf, err := loadConf(*configPath)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	a := &app{conf: conf}
+	err = cmd.run(a, args)
+	if a.client != nil {
+		a.client.Close()
+	}
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case err != nil:
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config path] <command> [flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-10s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+	flag.PrintDefaults()
+}
+
+func (a *app) connect() (*storage.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
 	pqCfg := &storage.Config{
-		PGHostname: conf["postgres_hostname"],
-		PGPort:     conf["postgres_port"],
-		PGDBName:   conf["postgres_db_name"],
-		PGUser:     conf["postgres_user"],
-		PGPassword: conf["postgres_password"],
+		PGHostname: a.conf["postgres_hostname"],
+		PGPort:     a.conf["postgres_port"],
+		PGDBName:   a.conf["postgres_db_name"],
+		PGUser:     a.conf["postgres_user"],
+		PGPassword: a.conf["postgres_password"],
 	}
 	pqClient, err := storage.NewClient(pqCfg)
 	if err != nil {
-		fmt.Printf("could not connect to postgres: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("could not connect to postgres: %v", err)
+	}
+	a.client = pqClient
+
+	return pqClient, nil
+}
+
+func (a *app) miniflux() *source.Miniflux {
+	return source.NewMiniflux(a.conf["miniflux_hostname"], a.conf["miniflux_api_key"])
+}
+
+func (a *app) repo() (*storage.CliRepo, error) {
+	pqClient, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewCliRepo(pqClient.DB()), nil
+}
+
+// newFlagSet returns the flags for a command, including the output format.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := fs.String("format", formatTable, "output format: "+strings.Join(formats, ", "))
+	return fs, format
+}
+
+func (a *app) migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pqClient, err := a.connect()
+	if err != nil {
+		return err
+	}
+	count, err := pqClient.Migrations()
+	if err != nil {
+		return err
 	}
-	defer pqClient.Close()
+	fmt.Printf("database is up to date, %d migrations applied\n", count)
 
-	cliRepo := storage.NewCliRepo(pqClient.DB())
-	summary := GenerateSummary(cliRepo)
-	PrintMatrix(summary)
+	return nil
 }
 
 func loadConf(path string) (map[string]string, error) {
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+const opmlNamespace = "https://go-mod.ewintr.nl/algorithmic-rss"
+
+type opml struct {
+	XMLName   xml.Name    `xml:"opml"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns:arss,attr,omitempty"`
+	Title     string      `xml:"head>title"`
+	Created   string      `xml:"head>dateCreated,omitempty"`
+	Outlines  []opmlEntry `xml:"body>outline"`
+}
+
+// opmlEntry is either a category with feeds as children or a feed. The arss
+// attributes hold the quality of the feed as computed from the ratings.
+type opmlEntry struct {
+	Type          string      `xml:"type,attr,omitempty"`
+	Text          string      `xml:"text,attr"`
+	Title         string      `xml:"title,attr,omitempty"`
+	XMLURL        string      `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL       string      `xml:"htmlUrl,attr,omitempty"`
+	Rated         string      `xml:"arss:rated,attr,omitempty"`
+	FinishRate    string      `xml:"arss:finishRate,attr,omitempty"`
+	NotOpenedRate string      `xml:"arss:notOpenedRate,attr,omitempty"`
+	Wilson        string      `xml:"arss:wilson,attr,omitempty"`
+	Outlines      []opmlEntry `xml:"outline"`
+}
+
+func (a *app) opml(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" {
+		fmt.Println("Usage: opml <export|import> [flags]")
+		return nil
+	}
+
+	switch args[0] {
+	case "export":
+		return a.opmlExport(args[1:])
+	case "import":
+		return a.opmlImport(args[1:])
+	default:
+		return fmt.Errorf("unknown opml command: %s", args[0])
+	}
+}
+
+// opmlExport writes the feeds in the database as OPML 2.0, grouped by
+// category.
+func (a *app) opmlExport(args []string) error {
+	fs := flag.NewFlagSet("opml export", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write to, default stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pqClient, err := a.connect()
+	if err != nil {
+		return err
+	}
+	feeds, err := storage.NewTuiRepo(pqClient.DB()).Feeds()
+	if err != nil {
+		return err
+	}
+	repo := storage.NewCliRepo(pqClient.DB())
+	names, err := repo.CategoryNames()
+	if err != nil {
+		return err
+	}
+	stats, err := repo.FeedStats()
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return writeOPML(w, feeds, names, stats)
+}
+
+func writeOPML(w io.Writer, feeds []domain.Feed, names map[int64]string, stats []storage.FeedStat) error {
+	statsByFeed := make(map[int64]storage.FeedStat, len(stats))
+	for _, s := range stats {
+		statsByFeed[s.FeedID] = s
+	}
+	byCategory := make(map[int64][]domain.Feed)
+	for _, f := range feeds {
+		byCategory[f.CategoryID] = append(byCategory[f.CategoryID], f)
+	}
+	catIDs := make([]int64, 0, len(byCategory))
+	for id := range byCategory {
+		catIDs = append(catIDs, id)
+	}
+	slices.Sort(catIDs)
+
+	doc := opml{
+		Version:   "2.0",
+		Namespace: opmlNamespace,
+		Title:     "Algorithmic RSS feeds",
+		Created:   time.Now().Format(time.RFC1123Z),
+	}
+	for _, catID := range catIDs {
+		cat := opmlEntry{Text: names[catID], Title: names[catID]}
+		catFeeds := byCategory[catID]
+		slices.SortFunc(catFeeds, func(a, b domain.Feed) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		})
+		for _, f := range catFeeds {
+			feed := opmlEntry{
+				Type:    "rss",
+				Text:    f.Title,
+				Title:   f.Title,
+				XMLURL:  f.FeedURL,
+				HTMLURL: f.SiteURL,
+			}
+			if s, ok := statsByFeed[f.ID]; ok {
+				lower, _ := wilson(s.Finished, s.Rated)
+				feed.Rated = fmt.Sprintf("%d", s.Rated)
+				feed.FinishRate = fmt.Sprintf("%.3f", rate(s.Finished, s.Rated))
+				feed.NotOpenedRate = fmt.Sprintf("%.3f", rate(s.NotOpened, s.Rated))
+				feed.Wilson = fmt.Sprintf("%.3f", lower)
+			}
+			cat.Outlines = append(cat.Outlines, feed)
+		}
+		doc.Outlines = append(doc.Outlines, cat)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("could not encode opml: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// opmlImport subscribes to the feeds in an OPML file in Miniflux, creating
+// missing categories, and then updates the feed table. Feeds that are already
+// subscribed are skipped.
+func (a *app) opmlImport(args []string) error {
+	fs := flag.NewFlagSet("opml import", flag.ContinueOnError)
+	defaultCategory := fs.String("category", "All", "category for feeds that are not in a category in the file")
+	dryRun := fs.Bool("dry-run", false, "only show what would be created")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected one opml file")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("could not read opml: %v", err)
+	}
+	var doc opml
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("could not parse opml: %v", err)
+	}
+
+	mf := a.miniflux()
+	cats, err := mf.Categories()
+	if err != nil {
+		return fmt.Errorf("could not fetch miniflux categories: %v", err)
+	}
+	catIDs := make(map[string]int64)
+	for _, c := range cats {
+		catIDs[strings.ToLower(c.Title)] = c.ID
+	}
+	feeds, err := mf.Feeds()
+	if err != nil {
+		return fmt.Errorf("could not fetch miniflux feeds: %v", err)
+	}
+	subscribed := make(map[string]bool)
+	for _, f := range feeds {
+		subscribed[f.FeedURL] = true
+	}
+
+	var created, skipped int
+	var walk func(outlines []opmlEntry, category string) error
+	walk = func(outlines []opmlEntry, category string) error {
+		for _, o := range outlines {
+			if o.XMLURL == "" {
+				title := o.Title
+				if title == "" {
+					title = o.Text
+				}
+				if err := walk(o.Outlines, title); err != nil {
+					return err
+				}
+				continue
+			}
+			if subscribed[o.XMLURL] {
+				skipped++
+				continue
+			}
+			fmt.Printf("%s: %s\n", category, o.XMLURL)
+			subscribed[o.XMLURL] = true
+			created++
+			if *dryRun {
+				continue
+			}
+
+			catID, ok := catIDs[strings.ToLower(category)]
+			if !ok {
+				cat, err := mf.CreateCategory(category)
+				if err != nil {
+					return fmt.Errorf("could not create category %q: %v", category, err)
+				}
+				catID = cat.ID
+				catIDs[strings.ToLower(category)] = catID
+			}
+			if _, err := mf.CreateFeed(o.XMLURL, catID); err != nil {
+				return fmt.Errorf("could not create feed %s: %v", o.XMLURL, err)
+			}
+		}
+		return nil
+	}
+	if err := walk(doc.Outlines, *defaultCategory); err != nil {
+		return err
+	}
+	fmt.Printf("%d feeds created, %d already subscribed\n", created, skipped)
+	if *dryRun || created == 0 {
+		return nil
+	}
+
+	pqClient, err := a.connect()
+	if err != nil {
+		return err
+	}
+
+	return mf.SyncTo(storage.NewTuiRepo(pqClient.DB()))
+}
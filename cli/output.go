@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+var formats = []string{formatTable, formatJSON, formatCSV, formatJSONL}
+
+// Report is a table of values that can be written in any of the output
+// formats.
+type Report struct {
+	Columns []string
+	Rows    [][]any
+}
+
+func (r *Report) Add(row ...any) {
+	r.Rows = append(r.Rows, row)
+}
+
+// Sort orders the rows on the values in the named column.
+func (r Report) Sort(column string, desc bool) error {
+	idx := slices.Index(r.Columns, column)
+	if idx < 0 {
+		return fmt.Errorf("unknown column %q, choose from %s", column, strings.Join(r.Columns, ", "))
+	}
+	slices.SortStableFunc(r.Rows, func(a, b []any) int {
+		c := compareValues(a[idx], b[idx])
+		if desc {
+			return -c
+		}
+		return c
+	})
+
+	return nil
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			return cmp.Compare(a, b)
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	}
+
+	return strings.Compare(formatValue(a), formatValue(b))
+}
+
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case formatTable:
+		return r.writeTable(w)
+	case formatJSON:
+		return r.writeJSON(w)
+	case formatCSV:
+		return r.writeCSV(w)
+	case formatJSONL:
+		return r.writeJSONL(w)
+	default:
+		return fmt.Errorf("unknown format %q, choose from %s", format, strings.Join(formats, ", "))
+	}
+}
+
+func (r Report) writeTable(w io.Writer) error {
+	widths := make([]int, len(r.Columns))
+	for i, c := range r.Columns {
+		widths[i] = len(c)
+	}
+	cells := make([][]string, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		line := make([]string, len(row))
+		for i, v := range row {
+			line[i] = formatValue(v)
+			widths[i] = max(widths[i], len([]rune(line[i])))
+		}
+		cells = append(cells, line)
+	}
+
+	writeLine := func(values []string) error {
+		var sb strings.Builder
+		for i, v := range values {
+			fmt.Fprintf(&sb, "| %-*s ", widths[i], v)
+		}
+		sb.WriteString("|\n")
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	if err := writeLine(r.Columns); err != nil {
+		return err
+	}
+	sep := 1
+	for _, width := range widths {
+		sep += width + 3
+	}
+	if _, err := fmt.Fprintln(w, strings.Repeat("+", sep)); err != nil {
+		return err
+	}
+	for _, line := range cells {
+		if err := writeLine(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeJSON writes an array of objects, keeping the column order.
+func (r Report) writeJSON(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range r.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := r.encodeRow(&buf, row); err != nil {
+			return err
+		}
+	}
+	if len(r.Rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeJSONL writes one object per line.
+func (r Report) writeJSONL(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, row := range r.Rows {
+		buf.Reset()
+		if err := r.encodeRow(&buf, row); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r Report) encodeRow(buf *bytes.Buffer, row []any) error {
+	buf.WriteString("{")
+	for j, v := range row {
+		if j > 0 {
+			buf.WriteString(", ")
+		}
+		key, err := json.Marshal(r.Columns[j])
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+
+	return nil
+}
+
+func (r Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(r.Columns); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		line := make([]string, len(row))
+		for i, v := range row {
+			line[i] = formatValue(v)
+		}
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return fmt.Sprintf("%.3f", v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+)
+
+func (a *app) ratings(args []string) error {
+	fs, format := newFlagSet("ratings")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	counts, err := repo.RatingsByStatus()
+	if err != nil {
+		return err
+	}
+	ratings, err := repo.RatingValues()
+	if err != nil {
+		return err
+	}
+
+	report := Report{Columns: []string{"rating", "count"}}
+	for _, rating := range ratings {
+		report.Add(rating, counts[rating])
+	}
+
+	return report.Write(os.Stdout, *format)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+func (a *app) rules(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" {
+		fmt.Println("Usage: rules <list|test|mine|candidates|accept> [flags]")
+		return nil
+	}
+
+	switch args[0] {
+	case "list":
+		return a.rulesList(args[1:])
+	case "test":
+		return a.rulesTest(args[1:])
+	case "mine":
+		return a.rulesMine(args[1:])
+	case "candidates":
+		return a.rulesCandidates(args[1:])
+	case "accept":
+		return a.rulesAccept(args[1:])
+	default:
+		return fmt.Errorf("unknown rules command: %s", args[0])
+	}
+}
+
+func (a *app) rulesList(args []string) error {
+	fs, format := newFlagSet("rules list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rules, err := a.allRules()
+	if err != nil {
+		return err
+	}
+
+	report := Report{Columns: []string{"name", "category_id", "host", "path_prefix", "path_contains", "term", "max_age", "action", "weight"}}
+	for _, r := range rules {
+		maxAge := ""
+		if r.MaxAge != 0 {
+			maxAge = r.MaxAge.String()
+		}
+		report.Add(r.Name, r.CategoryID, r.Host, r.PathPrefix, r.PathContains, r.Term, maxAge, string(r.Action), r.Weight)
+	}
+
+	return report.Write(os.Stdout, *format)
+}
+
+// rulesTest shows which rules match the given urls, as if they were new
+// entries in the category.
+func (a *app) rulesTest(args []string) error {
+	fs, format := newFlagSet("rules test")
+	categoryID := fs.Int64("category", domain.CatVideo, "category of the entries")
+	published := fs.String("published", "", "publication date of the entries, yyyy-mm-dd, default now")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no urls to test")
+	}
+
+	rules, err := a.allRules()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pubDate := now
+	if *published != "" {
+		if pubDate, err = time.ParseInLocation(time.DateOnly, *published, time.Local); err != nil {
+			return fmt.Errorf("invalid publication date: %v", err)
+		}
+	}
+
+	report := Report{Columns: []string{"url", "rule", "action"}}
+	for _, link := range fs.Args() {
+		entry := domain.Entry{URL: link, Published: pubDate}
+		matched := rules.Matching(entry, *categoryID, now)
+		if len(matched) == 0 {
+			report.Add(link, "", "keep")
+			continue
+		}
+		for _, r := range matched {
+			report.Add(link, r.Name, string(r.Action))
+		}
+	}
+
+	return report.Write(os.Stdout, *format)
+}
+
+// allRules returns the default rules and the ones that were accepted from
+// the mined candidates.
+func (a *app) allRules() (scoring.Rules, error) {
+	repo, err := a.repo()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := repo.Rules()
+	if err != nil {
+		return nil, fmt.Errorf("could not get accepted rules: %v", err)
+	}
+
+	return scoring.DefaultRules.With(stored), nil
+}
+
+// rulesMine looks for hosts and terms that predict the rating of an entry in
+// a category and stores them as candidates, replacing those of the previous
+// run. Hosts and terms that already have a rule for the category are left
+// out.
+func (a *app) rulesMine(args []string) error {
+	fs, format := newFlagSet("rules mine")
+	support := fs.Int("support", 10, "minimum number of rated entries with the host or term")
+	confidence := fs.Float64("confidence", 0.9, "minimum share of those entries with the same rating")
+	grams := fs.Int("grams", 2, "maximum number of words in a term")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *support < 1 || *confidence <= 0 || *confidence > 1 || *grams < 1 {
+		return fmt.Errorf("support and grams must be at least 1, confidence between 0 and 1")
+	}
+
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	rated, err := repo.RatedEntriesByCategory()
+	if err != nil {
+		return fmt.Errorf("could not get rated entries: %v", err)
+	}
+	stored, err := repo.Rules()
+	if err != nil {
+		return fmt.Errorf("could not get accepted rules: %v", err)
+	}
+
+	opts := scoring.MineOptions{
+		MinSupport:    *support,
+		MinConfidence: *confidence,
+		MaxGram:       *grams,
+	}
+	candidates := make([]scoring.RuleCandidate, 0)
+	for catID, entries := range rated {
+		candidates = append(candidates, scoring.Mine(catID, entries, opts)...)
+	}
+	candidates = slices.DeleteFunc(candidates, func(c scoring.RuleCandidate) bool {
+		return slices.ContainsFunc(stored, func(r scoring.Rule) bool {
+			return r.CategoryID == c.CategoryID && r.Host == c.Host && r.Term == c.Term && r.Action == c.Action
+		})
+	})
+	if err := repo.ReplaceRuleCandidates(candidates, time.Now()); err != nil {
+		return fmt.Errorf("could not store rule candidates: %v", err)
+	}
+
+	return writeCandidates(repo, *format)
+}
+
+func (a *app) rulesCandidates(args []string) error {
+	fs, format := newFlagSet("rules candidates")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+
+	return writeCandidates(repo, *format)
+}
+
+func writeCandidates(repo *storage.CliRepo, format string) error {
+	candidates, err := repo.RuleCandidates()
+	if err != nil {
+		return fmt.Errorf("could not get rule candidates: %v", err)
+	}
+
+	names, err := repo.CategoryNames()
+	if err != nil {
+		return fmt.Errorf("could not get category names: %v", err)
+	}
+
+	report := Report{Columns: []string{"id", "category", "host", "term", "action", "weight", "support", "confidence"}}
+	for _, c := range candidates {
+		report.Add(c.ID, names[c.CategoryID], c.Host, c.Term, string(c.Action), c.Weight, c.Support, c.Confidence)
+	}
+
+	return report.Write(os.Stdout, format)
+}
+
+func (a *app) rulesAccept(args []string) error {
+	fs := flag.NewFlagSet("rules accept", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no candidate ids to accept")
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid candidate id: %s", arg)
+		}
+		rule, err := repo.AcceptRuleCandidate(id, now)
+		if err != nil {
+			return fmt.Errorf("could not accept candidate %d: %v", id, err)
+		}
+		fmt.Printf("accepted rule %s\n", rule.Name)
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 
 	"go-mod.ewintr.nl/algorithmic-rss/storage"
@@ -9,57 +11,102 @@ import (
 
 type Summary struct {
 	TotalEntries   int64
-	ByCategory     map[int64]int64
-	ByRating       map[string]int64
 	CategoryRating map[int64]map[string]int64
-	AllRatings     []string
+	Ratings        []string
 	CategoryNames  map[int64]string
 }
 
-func GenerateSummary(repo *storage.CliRepo) Summary {
-	total, err := repo.TotalEntries()
+func (a *app) summary(args []string) error {
+	fs, format := newFlagSet("summary")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	repo, err := a.repo()
 	if err != nil {
-		fmt.Printf("Warning: could not get total entries: %v\n", err)
+		return err
 	}
-	byCategory, err := repo.EntriesByCategory()
+
+	summary, err := GenerateSummary(repo)
 	if err != nil {
-		fmt.Printf("Warning: could not get entries by category: %v\n", err)
+		return err
 	}
-	byRating, err := repo.RatingsByStatus()
+	if *format == formatTable {
+		PrintMatrix(summary)
+		return nil
+	}
+
+	return MatrixReport(summary).Write(os.Stdout, *format)
+}
+
+func GenerateSummary(repo *storage.CliRepo) (Summary, error) {
+	total, err := repo.TotalEntries()
 	if err != nil {
-		fmt.Printf("Warning: could not get ratings by status: %v\n", err)
+		return Summary{}, fmt.Errorf("could not get total entries: %v", err)
 	}
 	categoryRating, err := repo.CategoryRatingMatrix()
 	if err != nil {
-		fmt.Printf("Warning: could not get category rating matrix: %v\n", err)
+		return Summary{}, fmt.Errorf("could not get category rating matrix: %v", err)
 	}
-	allRatings, err := repo.AllRatings()
+	ratings, err := repo.RatingValues()
 	if err != nil {
-		fmt.Printf("Warning: could not get all ratings: %v\n", err)
+		return Summary{}, fmt.Errorf("could not get ratings: %v", err)
 	}
 	categoryNames, err := repo.CategoryNames()
 	if err != nil {
-		fmt.Printf("Warning: could not get category names: %v\n", err)
+		return Summary{}, fmt.Errorf("could not get category names: %v", err)
 	}
 
 	return Summary{
 		TotalEntries:   total,
-		ByCategory:     byCategory,
-		ByRating:       byRating,
 		CategoryRating: categoryRating,
-		AllRatings:     allRatings,
+		Ratings:        ratings,
 		CategoryNames:  categoryNames,
+	}, nil
+}
+
+// CategoryIDs returns the categories in the matrix, sorted.
+func (s Summary) CategoryIDs() []int64 {
+	catIDs := make([]int64, 0, len(s.CategoryRating))
+	for catID := range s.CategoryRating {
+		catIDs = append(catIDs, catID)
 	}
+	slices.Sort(catIDs)
+
+	return catIDs
 }
 
-func PrintMatrix(s Summary) {
-	if len(s.AllRatings) == 0 {
-		fmt.Println("(no data)")
-		return
+func (s Summary) CategoryTotal(catID int64) int64 {
+	var total int64
+	for _, count := range s.CategoryRating[catID] {
+		total += count
+	}
+	return total
+}
+
+func (s Summary) RatingTotal(rating string) int64 {
+	var total int64
+	for _, counts := range s.CategoryRating {
+		total += counts[rating]
+	}
+	return total
+}
+
+func (s Summary) MatrixTotal() int64 {
+	var total int64
+	for catID := range s.CategoryRating {
+		total += s.CategoryTotal(catID)
 	}
+	return total
+}
 
-	ratings := []string{"not_opened", "only_comments", "not_finished", "finished"}
+func (s Summary) categoryLabel(catID int64) string {
+	if title, ok := s.CategoryNames[catID]; ok {
+		return fmt.Sprintf("%d (%s)", catID, title)
+	}
+	return fmt.Sprintf("%d", catID)
+}
 
+func PrintMatrix(s Summary) {
 	fmt.Println("Database Summary")
 	fmt.Println("================")
 	fmt.Printf("Total: %d entries\n\n", s.TotalEntries)
@@ -70,29 +117,74 @@ func PrintMatrix(s Summary) {
 		return
 	}
 
+	columns := append(slices.Clone(s.Ratings), "total")
 	colWidth := 15
+	for _, c := range columns {
+		colWidth = max(colWidth, len(c)+1)
+	}
 
 	header := fmt.Sprintf("| %-24s ", "")
-	for _, rating := range ratings {
-		header += fmt.Sprintf("| %-*s ", colWidth-1, rating)
+	for _, c := range columns {
+		header += fmt.Sprintf("| %-*s ", colWidth-1, c)
 	}
 	header += "|"
 	fmt.Println(header)
 	fmt.Println(strings.Repeat("+", len(header)))
 
-	for catID, counts := range s.CategoryRating {
-		name := fmt.Sprintf("%d", catID)
-		if title, ok := s.CategoryNames[catID]; ok {
-			name = fmt.Sprintf("%d (%s)", catID, title)
-		}
+	printRow := func(name string, counts []int64, total int64) {
 		row := fmt.Sprintf("| %-24s ", name)
-		for _, rating := range ratings {
-			count := counts[rating]
-			row += fmt.Sprintf("| %-*d ", colWidth-1, count)
+		for _, count := range counts {
+			row += fmt.Sprintf("| %-*s ", colWidth-1, fmt.Sprintf("%d (%.0f%%)", count, rate(count, total)*100))
 		}
 		row += "|"
 		fmt.Println(row)
 	}
 
+	for _, catID := range s.CategoryIDs() {
+		counts := make([]int64, 0, len(columns))
+		for _, rating := range s.Ratings {
+			counts = append(counts, s.CategoryRating[catID][rating])
+		}
+		total := s.CategoryTotal(catID)
+		printRow(s.categoryLabel(catID), append(counts, total), total)
+	}
+
 	fmt.Println(strings.Repeat("+", len(header)))
+
+	counts := make([]int64, 0, len(columns))
+	for _, rating := range s.Ratings {
+		counts = append(counts, s.RatingTotal(rating))
+	}
+	total := s.MatrixTotal()
+	printRow("total", append(counts, total), total)
+}
+
+// MatrixReport has a row per category with the count and the share of each
+// rating, followed by a row with the totals.
+func MatrixReport(s Summary) Report {
+	report := Report{Columns: []string{"category_id", "category"}}
+	for _, rating := range s.Ratings {
+		report.Columns = append(report.Columns, rating, rating+"_pct")
+	}
+	report.Columns = append(report.Columns, "total")
+
+	for _, catID := range s.CategoryIDs() {
+		total := s.CategoryTotal(catID)
+		row := []any{catID, s.CategoryNames[catID]}
+		for _, rating := range s.Ratings {
+			count := s.CategoryRating[catID][rating]
+			row = append(row, count, rate(count, total)*100)
+		}
+		report.Add(append(row, total)...)
+	}
+
+	total := s.MatrixTotal()
+	row := []any{int64(0), "total"}
+	for _, rating := range s.Ratings {
+		count := s.RatingTotal(rating)
+		row = append(row, count, rate(count, total)*100)
+	}
+	report.Add(append(row, total)...)
+
+	return report
 }
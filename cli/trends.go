@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	formatChart = "chart"
+	chartWidth  = 40
+)
+
+// trends shows how the ratings develop over time, per category or per feed.
+func (a *app) trends(args []string) error {
+	fs, format := newFlagSet("trends")
+	fs.Lookup("format").Usage += ", " + formatChart
+	bucket := fs.String("bucket", "week", "size of the time buckets: day, week or month")
+	by := fs.String("by", "category", "group on category or feed")
+	since := fs.String("since", "", "only ratings from this date on, yyyy-mm-dd, default one year ago")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	from := time.Now().AddDate(-1, 0, 0)
+	if *since != "" {
+		var err error
+		if from, err = time.ParseInLocation(time.DateOnly, *since, time.Local); err != nil {
+			return fmt.Errorf("invalid date: %v", err)
+		}
+	}
+	repo, err := a.repo()
+	if err != nil {
+		return err
+	}
+	counts, err := repo.RatingTrends(*bucket, *by, from)
+	if err != nil {
+		return err
+	}
+	ratings, err := repo.RatingValues()
+	if err != nil {
+		return err
+	}
+	var names map[int64]string
+	if *by == "feed" {
+		names, err = repo.FeedNames()
+	} else {
+		names, err = repo.CategoryNames()
+	}
+	if err != nil {
+		return err
+	}
+
+	report := Report{Columns: append(append([]string{"bucket", *by + "_id", *by}, ratings...), "total", "finish_rate")}
+	type key struct {
+		bucket  time.Time
+		groupID int64
+	}
+	rows := make(map[key][]any)
+	keys := make([]key, 0)
+	for _, c := range counts {
+		k := key{bucket: c.Bucket, groupID: c.GroupID}
+		if _, ok := rows[k]; !ok {
+			row := []any{c.Bucket.Format(time.DateOnly), c.GroupID, names[c.GroupID]}
+			for range ratings {
+				row = append(row, int64(0))
+			}
+			rows[k] = append(row, int64(0), float64(0))
+			keys = append(keys, k)
+		}
+		row := rows[k]
+		for i, rating := range ratings {
+			if rating == c.Rating {
+				row[3+i] = row[3+i].(int64) + c.Count
+			}
+		}
+		row[3+len(ratings)] = row[3+len(ratings)].(int64) + c.Count
+	}
+	for _, k := range keys {
+		row := rows[k]
+		var finished int64
+		for i, rating := range ratings {
+			if rating == "finished" {
+				finished = row[3+i].(int64)
+			}
+		}
+		row[4+len(ratings)] = rate(finished, row[3+len(ratings)].(int64))
+		report.Add(row...)
+	}
+	if err := report.Sort(*by, false); err != nil {
+		return err
+	}
+
+	if *format == formatChart {
+		return writeTrendChart(os.Stdout, report, len(ratings))
+	}
+	return report.Write(os.Stdout, *format)
+}
+
+// writeTrendChart draws a bar with the finish rate for each bucket, grouped
+// per category or feed.
+func writeTrendChart(w io.Writer, report Report, ratingCount int) error {
+	var group string
+	for _, row := range report.Rows {
+		if name := formatValue(row[2]); name != group {
+			group = name
+			if _, err := fmt.Fprintf(w, "\n%s\n", group); err != nil {
+				return err
+			}
+		}
+		total := row[3+ratingCount].(int64)
+		finishRate := row[4+ratingCount].(float64)
+		filled := int(finishRate*chartWidth + 0.5)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", chartWidth-filled)
+		if _, err := fmt.Fprintf(w, "  %s %s %3.0f%% of %d\n", row[0], bar, finishRate*100, total); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
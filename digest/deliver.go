@@ -0,0 +1,109 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Deliverer interface {
+	Deliver(d Digest) error
+}
+
+// Dir writes the digest as a Markdown and an HTML file in a directory.
+type Dir struct {
+	Path string
+}
+
+func (dir Dir) Deliver(d Digest) error {
+	html, err := d.HTML()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir.Path, 0o755); err != nil {
+		return fmt.Errorf("could not create digest directory: %v", err)
+	}
+	base := filepath.Join(dir.Path, "digest-"+d.Date.Format(time.DateOnly))
+	if err := os.WriteFile(base+".md", []byte(d.Markdown()), 0o644); err != nil {
+		return fmt.Errorf("could not write digest: %v", err)
+	}
+	if err := os.WriteFile(base+".html", []byte(html), 0o644); err != nil {
+		return fmt.Errorf("could not write digest: %v", err)
+	}
+
+	return nil
+}
+
+// SMTP mails the digest with the Markdown as plain text alternative. Without
+// username no authentication is done, which is what a local test server like
+// MailHog expects.
+type SMTP struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (s SMTP) Deliver(d Digest) error {
+	msg, err := s.message(d)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	if err := smtp.SendMail(s.Addr, auth, s.From, s.To, msg); err != nil {
+		return fmt.Errorf("could not send digest: %v", err)
+	}
+
+	return nil
+}
+
+func (s SMTP) message(d Digest) ([]byte, error) {
+	html, err := d.HTML()
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", d.Markdown()},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", d.Title()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
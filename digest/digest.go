@@ -0,0 +1,111 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+type Item struct {
+	Entry   domain.Entry
+	Feed    string
+	Score   float64
+	Summary string
+}
+
+type Section struct {
+	Title string
+	Items []Item
+}
+
+type Digest struct {
+	Date     time.Time
+	Sections []Section
+}
+
+// Summarize fills in the summary of every item. When the summarizer fails
+// for an item, the error is kept as its summary so that one bad entry does
+// not block the digest.
+func (d *Digest) Summarize(ctx context.Context, s Summarizer) error {
+	for i := range d.Sections {
+		for j := range d.Sections[i].Items {
+			item := &d.Sections[i].Items[j]
+			summary, err := s.Summarize(ctx, item.Entry)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				summary = fmt.Sprintf("(could not summarize: %v)", err)
+			}
+			item.Summary = summary
+		}
+	}
+
+	return nil
+}
+
+func (d Digest) Title() string {
+	return fmt.Sprintf("Digest %s", d.Date.Format(time.DateOnly))
+}
+
+// markdownEscaper escapes the characters in titles and feed names that would
+// otherwise be read as Markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", `\<`,
+)
+
+func (d Digest) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", d.Title())
+	for _, s := range d.Sections {
+		fmt.Fprintf(&sb, "\n## %s\n", s.Title)
+		if len(s.Items) == 0 {
+			sb.WriteString("\nNothing new.\n")
+		}
+		for _, item := range s.Items {
+			fmt.Fprintf(&sb, "\n### [%s](<%s>)\n\n", markdownEscaper.Replace(item.Entry.Title), item.Entry.URL)
+			fmt.Fprintf(&sb, "*%s, score %.0f*\n\n", markdownEscaper.Replace(item.Feed), item.Score*100)
+			fmt.Fprintf(&sb, "%s\n", item.Summary)
+		}
+	}
+
+	return sb.String()
+}
+
+var htmlTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f", f*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{ range .Sections }}
+<h2>{{ .Title }}</h2>
+{{ range .Items }}
+<h3><a href="{{ .Entry.URL }}">{{ .Entry.Title }}</a></h3>
+<p><em>{{ .Feed }}, score {{ percent .Score }}</em></p>
+<p>{{ .Summary }}</p>
+{{ else }}
+<p>Nothing new.</p>
+{{ end }}
+{{ end }}
+</body>
+</html>
+`))
+
+func (d Digest) HTML() (string, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("could not render digest: %v", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,305 @@
+package digest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+func testDigest(t *testing.T) Digest {
+	t.Helper()
+	d := Digest{
+		Date: time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC),
+		Sections: []Section{
+			{
+				Title: "Aggregator",
+				Items: []Item{
+					{
+						Entry: domain.Entry{
+							Title:   "Go <generics> & [friends]",
+							URL:     "https://example.com/go",
+							Content: "one two three",
+						},
+						Feed:  "Example_feed",
+						Score: 0.82,
+					},
+				},
+			},
+			{Title: "Small web"},
+		},
+	}
+	if err := d.Summarize(context.Background(), Fake{}); err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+
+	return d
+}
+
+func TestFake(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		entry domain.Entry
+		exp   string
+	}{
+		{
+			name:  "empty content",
+			entry: domain.Entry{Title: "title"},
+			exp:   "title",
+		},
+		{
+			name:  "short content",
+			entry: domain.Entry{Content: "a  short\ntext"},
+			exp:   "a short text",
+		},
+		{
+			name:  "long content",
+			entry: domain.Entry{Content: strings.Repeat("word ", fakeSummaryWords+10)},
+			exp:   strings.TrimSpace(strings.Repeat("word ", fakeSummaryWords)) + " …",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			act, err := Fake{}.Summarize(context.Background(), tc.entry)
+			if err != nil {
+				t.Fatalf("exp nil, got %v", err)
+			}
+			if act != tc.exp {
+				t.Errorf("exp %q, got %q", tc.exp, act)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	act := testDigest(t).Markdown()
+	exp := `# Digest 2025-06-01
+
+## Aggregator
+
+### [Go \<generics> & \[friends\]](<https://example.com/go>)
+
+*Example\_feed, score 82*
+
+one two three
+
+## Small web
+
+Nothing new.
+`
+	if act != exp {
+		t.Errorf("exp\n%s\ngot\n%s", exp, act)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	act, err := testDigest(t).HTML()
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	for _, exp := range []string{
+		"<title>Digest 2025-06-01</title>",
+		`<h3><a href="https://example.com/go">Go &lt;generics&gt; &amp; [friends]</a></h3>`,
+		"<p><em>Example_feed, score 82</em></p>",
+		"<p>one two three</p>",
+		"<p>Nothing new.</p>",
+	} {
+		if !strings.Contains(act, exp) {
+			t.Errorf("exp %q in\n%s", exp, act)
+		}
+	}
+	if strings.Contains(act, "<generics>") {
+		t.Errorf("exp title to be escaped, got\n%s", act)
+	}
+}
+
+func TestDir(t *testing.T) {
+	d := testDigest(t)
+	dir := filepath.Join(t.TempDir(), "digests")
+	if err := (Dir{Path: dir}).Deliver(d); err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+
+	md, err := os.ReadFile(filepath.Join(dir, "digest-2025-06-01.md"))
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	if string(md) != d.Markdown() {
+		t.Errorf("exp markdown file to have the digest, got\n%s", md)
+	}
+	html, err := os.ReadFile(filepath.Join(dir, "digest-2025-06-01.html"))
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	expHTML, err := d.HTML()
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	if string(html) != expHTML {
+		t.Errorf("exp html file to have the digest, got\n%s", html)
+	}
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpStub accepts one mail on a local port, like MailHog would, and sends
+// it on the channel.
+func smtpStub(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	msgs := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tc := textproto.NewConn(conn)
+		var msg smtpMessage
+		tc.PrintfLine("220 localhost stub")
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				tc.PrintfLine("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				tc.PrintfLine("250 ok")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				tc.PrintfLine("250 ok")
+			case cmd == "DATA":
+				tc.PrintfLine("354 go ahead")
+				data, err := io.ReadAll(tc.DotReader())
+				if err != nil {
+					return
+				}
+				msg.data = string(data)
+				tc.PrintfLine("250 ok")
+			case cmd == "QUIT":
+				tc.PrintfLine("221 bye")
+				msgs <- msg
+				return
+			default:
+				tc.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), msgs
+}
+
+func TestSMTP(t *testing.T) {
+	addr, msgs := smtpStub(t)
+	d := testDigest(t)
+	s := SMTP{
+		Addr: addr,
+		From: "arss@example.com",
+		To:   []string{"one@example.com", "two@example.com"},
+	}
+	if err := s.Deliver(d); err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+
+	var msg smtpMessage
+	select {
+	case msg = <-msgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("exp a mail, got none")
+	}
+	if msg.from != s.From {
+		t.Errorf("exp %s, got %s", s.From, msg.from)
+	}
+	if strings.Join(msg.to, ",") != "one@example.com,two@example.com" {
+		t.Errorf("exp both recipients, got %v", msg.to)
+	}
+
+	m, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(msg.data)))
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	for _, h := range []struct {
+		key string
+		exp string
+	}{
+		{"From", "arss@example.com"},
+		{"To", "one@example.com, two@example.com"},
+		{"Subject", "Digest 2025-06-01"},
+		{"Mime-Version", "1.0"},
+	} {
+		if act := m.Header.Get(h.key); act != h.exp {
+			t.Errorf("exp %s %q, got %q", h.key, h.exp, act)
+		}
+	}
+	if _, err := m.Header.Date(); err != nil {
+		t.Errorf("exp a valid date, got %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("exp multipart/alternative, got %s", mediaType)
+	}
+	expHTML, err := d.HTML()
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+	exp := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", d.Markdown()},
+		{"text/html; charset=utf-8", expHTML},
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			if i != len(exp) {
+				t.Errorf("exp %d parts, got %d", len(exp), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("exp nil, got %v", err)
+		}
+		if i >= len(exp) {
+			t.Fatalf("exp %d parts, got more", len(exp))
+		}
+		if act := part.Header.Get("Content-Type"); act != exp[i].contentType {
+			t.Errorf("exp %s, got %s", exp[i].contentType, act)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("exp nil, got %v", err)
+		}
+		if act := strings.ReplaceAll(string(body), "\r\n", "\n"); act != exp[i].body {
+			t.Errorf("exp part %d\n%s\ngot\n%s", i, exp[i].body, act)
+		}
+	}
+}
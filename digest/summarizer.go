@@ -0,0 +1,92 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+const (
+	maxPromptContent = 8000
+	fakeSummaryWords = 40
+)
+
+type Summarizer interface {
+	Summarize(ctx context.Context, entry domain.Entry) (string, error)
+}
+
+// Ollama summarizes with a local LLM through the Ollama generate API.
+type Ollama struct {
+	URL    string
+	Model  string
+	Client *http.Client
+}
+
+func NewOllama(url, model string) *Ollama {
+	return &Ollama{
+		URL:    strings.TrimRight(url, "/"),
+		Model:  model,
+		Client: http.DefaultClient,
+	}
+}
+
+func (o *Ollama) Summarize(ctx context.Context, entry domain.Entry) (string, error) {
+	content := []rune(entry.Content)
+	if len(content) > maxPromptContent {
+		content = content[:maxPromptContent]
+	}
+	prompt := fmt.Sprintf("Summarize the following article in two or three sentences. Only return the summary.\n\nTitle: %s\n\n%s", entry.Title, string(content))
+	body, err := json.Marshal(map[string]any{
+		"model":  o.Model,
+		"prompt": prompt,
+		"stream": false,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not reach ollama: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ollama returned status %s", res.Status)
+	}
+
+	var result struct {
+		Response string `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("could not decode ollama response: %v", err)
+	}
+
+	return strings.TrimSpace(result.Response), nil
+}
+
+// Fake returns the first words of the content as summary. It gives the same
+// output for the same entry, which makes it useful for trying out the digest
+// without a running LLM.
+type Fake struct{}
+
+func (Fake) Summarize(_ context.Context, entry domain.Entry) (string, error) {
+	words := strings.Fields(entry.Content)
+	if len(words) == 0 {
+		return entry.Title, nil
+	}
+	if len(words) <= fakeSummaryWords {
+		return strings.Join(words, " "), nil
+	}
+
+	return strings.Join(words[:fakeSummaryWords], " ") + " …", nil
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type Category struct {
 	ID    int64
 	Title string
@@ -14,9 +16,36 @@ type Feed struct {
 }
 
 type Entry struct {
-	ID      int64
-	FeedID  int64
-	Title   string
-	URL     string
-	Content string
+	ID        int64
+	FeedID    int64
+	Title     string
+	URL       string
+	Content   string
+	Published time.Time
+}
+
+type RatedEntry struct {
+	Entry   Entry
+	Rating  string
+	Updated time.Time
+}
+
+// Edition is the set of entries the service kept for a category in one run.
+// It is shown until the next run, entries that arrive in the meantime wait
+// for the next edition.
+type Edition struct {
+	ID         int64
+	CategoryID int64
+	Created    time.Time
+	ValidUntil time.Time
+	EntryIDs   []int64
+}
+
+// SnoozedEntry is an entry that was dropped from an edition but kept aside,
+// so it can come back when a later edition has room.
+type SnoozedEntry struct {
+	Entry      Entry
+	CategoryID int64
+	Score      float64
+	Snoozed    time.Time
 }
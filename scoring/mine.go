@@ -0,0 +1,142 @@
+package scoring
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+const (
+	negativeRating = "not_opened"
+	positiveRating = "finished"
+)
+
+// RuleCandidate is a host or a term that was found to go together with one
+// rating much more often than usual in a category. Support is the number of
+// entries rated not_opened or finished that have it, Confidence the share of
+// those that had the rating the action is based on.
+type RuleCandidate struct {
+	ID         int64
+	CategoryID int64
+	Host       string
+	Term       string
+	Action     Action
+	Weight     float64
+	Support    int
+	Confidence float64
+}
+
+// Rule turns the candidate into a rule for its category.
+func (c RuleCandidate) Rule() Rule {
+	value := c.Host
+	if c.Term != "" {
+		value = strings.ReplaceAll(c.Term, " ", "-")
+	}
+
+	return Rule{
+		Name:       fmt.Sprintf("mined-%d-%s-%s", c.CategoryID, c.Action, value),
+		CategoryID: c.CategoryID,
+		Host:       c.Host,
+		Term:       c.Term,
+		Action:     c.Action,
+		Weight:     c.Weight,
+	}
+}
+
+type MineOptions struct {
+	MinSupport    int
+	MinConfidence float64
+	MaxGram       int
+}
+
+// Mine looks for hosts and title or content n-grams that are mostly seen in
+// entries of the category that were not opened, or mostly in entries that
+// were finished, and proposes them as skip and boost rules for the category.
+// Terms that only repeat a shorter term with the same action are left out.
+// Other ratings are ignored.
+func Mine(categoryID int64, rated []domain.RatedEntry, opts MineOptions) []RuleCandidate {
+	type feature struct {
+		host string
+		term string
+	}
+	stats := make(map[feature]counts)
+	var total counts
+	for _, r := range rated {
+		var label counts
+		switch r.Rating {
+		case negativeRating:
+			label.neg = 1
+		case positiveRating:
+			label.pos = 1
+		default:
+			continue
+		}
+		total.pos += label.pos
+		total.neg += label.neg
+
+		features := make([]feature, 0)
+		if link, err := url.Parse(r.Entry.URL); err == nil && link.Hostname() != "" {
+			features = append(features, feature{host: link.Hostname()})
+		}
+		for _, g := range Grams(r.Entry.Title+" "+r.Entry.Content, opts.MaxGram) {
+			features = append(features, feature{term: g})
+		}
+		for _, f := range features {
+			c := stats[f]
+			c.pos += label.pos
+			c.neg += label.neg
+			stats[f] = c
+		}
+	}
+	if total.total() == 0 {
+		return []RuleCandidate{}
+	}
+
+	found := make(map[feature]Action)
+	result := make([]RuleCandidate, 0)
+	for f, c := range stats {
+		if c.total() < float64(opts.MinSupport) {
+			continue
+		}
+		cand := RuleCandidate{CategoryID: categoryID, Host: f.host, Term: f.term, Support: int(c.total())}
+		negShare, posShare := c.neg/c.total(), c.pos/c.total()
+		switch {
+		case negShare >= opts.MinConfidence && negShare > total.neg/total.total():
+			cand.Action, cand.Confidence = ActionSkip, negShare
+		case posShare >= opts.MinConfidence && posShare > total.pos/total.total():
+			cand.Action, cand.Confidence = ActionBoost, posShare
+			cand.Weight = math.Log((c.pos+1)/(c.neg+1)) - math.Log((total.pos+1)/(total.neg+1))
+		default:
+			continue
+		}
+		found[f] = cand.Action
+		result = append(result, cand)
+	}
+
+	result = slices.DeleteFunc(result, func(c RuleCandidate) bool {
+		n := len(strings.Fields(c.Term))
+		if n < 2 {
+			return false
+		}
+		for _, g := range Grams(c.Term, n-1) {
+			if found[feature{term: g}] == c.Action {
+				return true
+			}
+		}
+		return false
+	})
+	slices.SortFunc(result, func(a, b RuleCandidate) int {
+		return cmp.Or(
+			cmp.Compare(b.Confidence, a.Confidence),
+			cmp.Compare(b.Support, a.Support),
+			cmp.Compare(a.Host+a.Term, b.Host+b.Term),
+		)
+	})
+
+	return result
+}
@@ -0,0 +1,173 @@
+package scoring
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+const (
+	feedPriorStrength = 5.0
+	minTermSupport    = 3.0
+	explainTerms      = 5
+)
+
+// RatingValues says how much a rating counts as positive feedback. Ratings
+// that are not listed are ignored when training. Entries imported from the
+// Miniflux history as read but not starred are a weak label: we know they
+// were seen, not whether they were any good.
+var RatingValues = map[string]float64{
+	"not_opened":    0,
+	"weak_read":     0.3,
+	"only_comments": 0.5,
+	"not_finished":  0.5,
+	"finished":      1,
+}
+
+type counts struct {
+	pos float64
+	neg float64
+}
+
+func (c counts) total() float64 {
+	return c.pos + c.neg
+}
+
+// Model is a naive Bayes style estimate of how likely an entry is to be read,
+// based on earlier ratings for its feed and the words it contains.
+type Model struct {
+	global counts
+	feeds  map[int64]counts
+	terms  map[string]counts
+}
+
+type TermWeight struct {
+	Term   string
+	Weight float64
+}
+
+type Explanation struct {
+	Score     float64
+	FeedPrior float64
+	Terms     []TermWeight
+	Rules     []string
+	Skip      bool
+}
+
+func Train(rated []domain.RatedEntry) *Model {
+	m := &Model{
+		feeds: make(map[int64]counts),
+		terms: make(map[string]counts),
+	}
+	for _, r := range rated {
+		v, ok := RatingValues[r.Rating]
+		if !ok {
+			continue
+		}
+		m.global.pos += v
+		m.global.neg += 1 - v
+
+		f := m.feeds[r.Entry.FeedID]
+		f.pos += v
+		f.neg += 1 - v
+		m.feeds[r.Entry.FeedID] = f
+
+		for _, t := range Tokens(r.Entry.Title + " " + r.Entry.Content) {
+			c := m.terms[t]
+			c.pos += v
+			c.neg += 1 - v
+			m.terms[t] = c
+		}
+	}
+
+	return m
+}
+
+// Size returns the number of ratings the model was trained on.
+func (m *Model) Size() int {
+	if m == nil {
+		return 0
+	}
+	return int(math.Round(m.global.total()))
+}
+
+func (m *Model) globalRate() float64 {
+	if m == nil {
+		return 0.5
+	}
+	return (m.global.pos + 1) / (m.global.total() + 2)
+}
+
+// FeedPrior is the smoothed share of positive ratings for a feed. Feeds with
+// few ratings stay close to the overall rate.
+func (m *Model) FeedPrior(feedID int64) float64 {
+	if m == nil {
+		return 0.5
+	}
+	f := m.feeds[feedID]
+	return (f.pos + feedPriorStrength*m.globalRate()) / (f.total() + feedPriorStrength)
+}
+
+// TermWeights returns the log odds contribution of every known term in the
+// entry, strongest first.
+func (m *Model) TermWeights(entry domain.Entry) []TermWeight {
+	weights := make([]TermWeight, 0)
+	if m == nil {
+		return weights
+	}
+	for _, t := range Tokens(entry.Title + " " + entry.Content) {
+		c, ok := m.terms[t]
+		if !ok || c.total() < minTermSupport {
+			continue
+		}
+		w := math.Log((c.pos+1)/(m.global.pos+2)) - math.Log((c.neg+1)/(m.global.neg+2))
+		weights = append(weights, TermWeight{Term: t, Weight: w})
+	}
+	sort.SliceStable(weights, func(i, j int) bool {
+		return math.Abs(weights[i].Weight) > math.Abs(weights[j].Weight)
+	})
+
+	return weights
+}
+
+// Scorer combines the trained model with the rule set.
+type Scorer struct {
+	Model *Model
+	Rules Rules
+}
+
+func (s Scorer) Score(entry domain.Entry, categoryID int64, now time.Time) Explanation {
+	exp := Explanation{
+		FeedPrior: s.Model.FeedPrior(entry.FeedID),
+		Rules:     make([]string, 0),
+	}
+
+	logit := math.Log(exp.FeedPrior / (1 - exp.FeedPrior))
+	weights := s.Model.TermWeights(entry)
+	if len(weights) > 0 {
+		var sum float64
+		for _, w := range weights {
+			sum += w.Weight
+		}
+		logit += sum / math.Sqrt(float64(len(weights)))
+	}
+	exp.Terms = weights[:min(explainTerms, len(weights))]
+
+	for _, r := range s.Rules.Matching(entry, categoryID, now) {
+		exp.Rules = append(exp.Rules, r.Name)
+		switch r.Action {
+		case ActionSkip:
+			exp.Skip = true
+		case ActionBoost:
+			logit += r.Weight
+		}
+	}
+
+	if !exp.Skip {
+		exp.Score = 1 / (1 + math.Exp(-logit))
+	}
+
+	return exp
+}
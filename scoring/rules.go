@@ -0,0 +1,122 @@
+package scoring
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+type Action string
+
+const (
+	ActionSkip  Action = "skip"
+	ActionBoost Action = "boost"
+)
+
+// Rule matches an entry when all of its non-empty conditions hold.
+type Rule struct {
+	Name         string
+	CategoryID   int64
+	Host         string
+	PathPrefix   string
+	PathContains string
+	Term         string
+	MaxAge       time.Duration
+	Action       Action
+	Weight       float64
+}
+
+type Rules []Rule
+
+var DefaultRules = Rules{
+	{
+		Name:       "youtube-shorts",
+		CategoryID: domain.CatVideo,
+		Host:       "www.youtube.com",
+		PathPrefix: "/shorts",
+		Action:     ActionSkip,
+	},
+	{
+		Name:         "ccc-german",
+		CategoryID:   domain.CatVideo,
+		Host:         "cdn.media.ccc.de",
+		PathContains: "-deu-",
+		Action:       ActionSkip,
+	},
+	{
+		Name:       "video-timeout",
+		CategoryID: domain.CatVideo,
+		MaxAge:     21 * 24 * time.Hour,
+		Action:     ActionSkip,
+	},
+	// {
+	// 	Name:       "aggregator-timeout",
+	// 	CategoryID: domain.CatNewsAggregator,
+	// 	MaxAge:     24 * time.Hour,
+	// 	Action:     ActionSkip,
+	// },
+	// {
+	// 	Name:       "smallweb-timeout",
+	// 	CategoryID: domain.CatSmallWeb,
+	// 	MaxAge:     48 * time.Hour,
+	// 	Action:     ActionSkip,
+	// },
+}
+
+func (r Rule) Match(entry domain.Entry, categoryID int64, now time.Time) bool {
+	if r.CategoryID != 0 && r.CategoryID != categoryID {
+		return false
+	}
+	if r.Host != "" || r.PathPrefix != "" || r.PathContains != "" {
+		link, err := url.Parse(entry.URL)
+		if err != nil {
+			return false
+		}
+		if r.Host != "" && link.Hostname() != r.Host {
+			return false
+		}
+		if r.PathPrefix != "" && !strings.HasPrefix(link.Path, r.PathPrefix) {
+			return false
+		}
+		if r.PathContains != "" && !strings.Contains(link.Path, r.PathContains) {
+			return false
+		}
+	}
+	if r.Term != "" && !ContainsTerm(entry.Title+" "+entry.Content, r.Term) {
+		return false
+	}
+	if r.MaxAge != 0 && now.Sub(entry.Published) <= r.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// With returns a new rule set with the rules of rs followed by more.
+func (rs Rules) With(more Rules) Rules {
+	return append(slices.Clone(rs), more...)
+}
+
+func (rs Rules) Matching(entry domain.Entry, categoryID int64, now time.Time) Rules {
+	matched := make(Rules, 0)
+	for _, r := range rs {
+		if r.Match(entry, categoryID, now) {
+			matched = append(matched, r)
+		}
+	}
+
+	return matched
+}
+
+func (rs Rules) Skip(entry domain.Entry, categoryID int64, now time.Time) bool {
+	for _, r := range rs.Matching(entry, categoryID, now) {
+		if r.Action == ActionSkip {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package scoring
+
+import (
+	"net/url"
+	"strings"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+// Constraints limit what the selection keeps. Max is the number of entries
+// to keep, zero keeps everything that fits the caps. FeedCap and HostCap limit
+// the entries per feed and per host, zero means no limit. FeedDailyCap limits
+// the entries per feed over the day, counting the KeptToday entries that were
+// kept in earlier runs. Diversity weighs the similarity to already kept
+// entries against the score, from 0 for only the score to 1 for mostly new
+// topics.
+//
+// The best entry of each Guaranteed feed is always kept, whatever the caps.
+// When the caps leave less than Min entries, the caps are dropped to fill up
+// to Min.
+type Constraints struct {
+	Min          int
+	Max          int
+	FeedCap      int
+	HostCap      int
+	FeedDailyCap int
+	KeptToday    map[int64]int
+	Guaranteed   []int64
+	Diversity    float64
+}
+
+// Candidate is an entry the selection can keep. Keep marks an entry that is
+// kept whatever the constraints, like an unread entry of the current edition.
+// It counts against the budget and the caps for the other candidates.
+type Candidate struct {
+	Entry domain.Entry
+	Score float64
+	Keep  bool
+}
+
+// Select picks entries with maximal marginal relevance: each round the
+// candidate with the best score, minus its similarity to what was already
+// picked, is kept. It returns the kept and the dropped entries.
+func Select(candidates []Candidate, c Constraints) ([]domain.Entry, []domain.Entry) {
+	tokens := make([]map[string]bool, len(candidates))
+	hosts := make([]string, len(candidates))
+	for i, cand := range candidates {
+		tokens[i] = make(map[string]bool)
+		for _, t := range Tokens(cand.Entry.Title + " " + cand.Entry.Content) {
+			tokens[i][t] = true
+		}
+		if u, err := url.Parse(cand.Entry.URL); err == nil {
+			hosts[i] = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		}
+	}
+
+	picked := make([]int, 0)
+	done := make([]bool, len(candidates))
+	perFeed := make(map[int64]int)
+	perHost := make(map[string]int)
+	full := func() bool {
+		return c.Max > 0 && len(picked) >= c.Max
+	}
+	// next returns the best candidate that is allowed, or -1
+	next := func(allowed func(i int) bool) int {
+		best, bestValue := -1, 0.0
+		for i, cand := range candidates {
+			if done[i] || !allowed(i) {
+				continue
+			}
+			var similarity float64
+			for _, p := range picked {
+				if c.Diversity == 0 {
+					break
+				}
+				similarity = max(similarity, jaccard(tokens[i], tokens[p]))
+			}
+			value := (1-c.Diversity)*cand.Score - c.Diversity*similarity
+			if best < 0 || value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		return best
+	}
+	pick := func(i int) {
+		done[i] = true
+		picked = append(picked, i)
+		perFeed[candidates[i].Entry.FeedID]++
+		perHost[hosts[i]]++
+	}
+
+	for i, cand := range candidates {
+		if cand.Keep {
+			pick(i)
+		}
+	}
+	for _, feedID := range c.Guaranteed {
+		if full() {
+			break
+		}
+		if perFeed[feedID] > 0 {
+			continue
+		}
+		if i := next(func(i int) bool { return candidates[i].Entry.FeedID == feedID }); i >= 0 {
+			pick(i)
+		}
+	}
+	withinCaps := func(i int) bool {
+		feedID := candidates[i].Entry.FeedID
+		switch {
+		case c.FeedCap > 0 && perFeed[feedID] >= c.FeedCap:
+			return false
+		case c.FeedDailyCap > 0 && c.KeptToday[feedID]+perFeed[feedID] >= c.FeedDailyCap:
+			return false
+		case c.HostCap > 0 && hosts[i] != "" && perHost[hosts[i]] >= c.HostCap:
+			return false
+		default:
+			return true
+		}
+	}
+	for !full() {
+		i := next(withinCaps)
+		if i < 0 {
+			break
+		}
+		pick(i)
+	}
+	for len(picked) < c.Min && !full() {
+		i := next(func(int) bool { return true })
+		if i < 0 {
+			break
+		}
+		pick(i)
+	}
+
+	kept := make([]domain.Entry, 0, len(picked))
+	for _, i := range picked {
+		kept = append(kept, candidates[i].Entry)
+	}
+	dropped := make([]domain.Entry, 0, len(candidates)-len(picked))
+	for i, cand := range candidates {
+		if !done[i] {
+			dropped = append(dropped, cand.Entry)
+		}
+	}
+
+	return kept, dropped
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var shared int
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
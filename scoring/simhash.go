@@ -0,0 +1,115 @@
+package scoring
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+const (
+	// DuplicateDistance is the largest number of differing bits for which two
+	// fingerprints are considered the same story.
+	DuplicateDistance = 3
+	titleWeight       = 3
+)
+
+// SimHash is a 64 bit fingerprint of the title and content of an entry.
+// Entries with almost the same words get fingerprints that differ in only a
+// few bits. Title words count more, since aggregators often only carry the
+// title and a link to the comments.
+func SimHash(entry domain.Entry) uint64 {
+	var v [64]int
+	add := func(text string, weight int) {
+		for _, t := range Tokens(text) {
+			h := fnv.New64a()
+			h.Write([]byte(t))
+			sum := h.Sum64()
+			for i := range 64 {
+				if sum&(1<<i) != 0 {
+					v[i] += weight
+				} else {
+					v[i] -= weight
+				}
+			}
+		}
+	}
+	add(entry.Title, titleWeight)
+	add(entry.Content, 1)
+
+	var fp uint64
+	for i := range 64 {
+		if v[i] > 0 {
+			fp |= 1 << i
+		}
+	}
+
+	return fp
+}
+
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Duplicate is an entry that tells the same story as the representative of
+// its cluster.
+type Duplicate struct {
+	Entry    domain.Entry
+	KeptID   int64
+	Distance int
+}
+
+// Cluster groups entries that link to the same page or have nearly the same
+// fingerprint. The first entry of a cluster is its representative, so sort
+// the entries on preference before clustering. It returns the representatives
+// in the original order and the duplicates that were merged into them.
+func Cluster(entries []domain.Entry, maxDistance int) ([]domain.Entry, []Duplicate) {
+	type rep struct {
+		entry domain.Entry
+		url   string
+		hash  uint64
+	}
+	reps := make([]rep, 0, len(entries))
+	dups := make([]Duplicate, 0)
+
+ENTRIES:
+	for _, e := range entries {
+		u, h := normalizeURL(e.URL), SimHash(e)
+		for _, r := range reps {
+			d := Distance(r.hash, h)
+			sameURL := u != "" && u == r.url
+			// an entry without words has no useful fingerprint
+			similar := h != 0 && r.hash != 0 && d <= maxDistance
+			if sameURL || similar {
+				dups = append(dups, Duplicate{Entry: e, KeptID: r.entry.ID, Distance: d})
+				continue ENTRIES
+			}
+		}
+		reps = append(reps, rep{entry: e, url: u, hash: h})
+	}
+
+	kept := make([]domain.Entry, 0, len(reps))
+	for _, r := range reps {
+		kept = append(kept, r.entry)
+	}
+
+	return kept, dups
+}
+
+// normalizeURL drops what differs between links to the same page, like the
+// scheme, a www. prefix, a trailing slash and the fragment.
+func normalizeURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.TrimSuffix(u.Path, "/")
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return host + path
+}
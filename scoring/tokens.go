@@ -0,0 +1,84 @@
+package scoring
+
+import (
+	"strings"
+	"unicode"
+)
+
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "any": true, "can": true, "had": true, "her": true,
+	"was": true, "one": true, "our": true, "out": true, "has": true, "his": true,
+	"how": true, "its": true, "may": true, "new": true, "now": true, "see": true,
+	"who": true, "did": true, "get": true, "him": true, "let": true, "she": true,
+	"too": true, "use": true, "that": true, "with": true, "this": true, "from": true,
+	"they": true, "have": true, "what": true, "when": true, "your": true, "will": true,
+	"there": true, "their": true, "about": true, "which": true, "would": true,
+	"been": true, "were": true, "into": true, "than": true, "then": true, "them": true,
+	"some": true, "more": true, "also": true, "just": true, "only": true, "like": true,
+	"http": true, "https": true, "www": true, "com": true,
+}
+
+// Tokens returns the distinct lower case words in text that are useful as
+// features, in order of first appearance.
+func Tokens(text string) []string {
+	ws := words(text)
+	seen := make(map[string]bool, len(ws))
+	tokens := make([]string, 0, len(ws))
+	for _, w := range ws {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		tokens = append(tokens, w)
+	}
+
+	return tokens
+}
+
+// Grams returns the distinct sequences of one up to n useful words in text,
+// joined by a space, in order of first appearance.
+func Grams(text string, n int) []string {
+	ws := words(text)
+	seen := make(map[string]bool, len(ws)*n)
+	grams := make([]string, 0, len(ws)*n)
+	for i := range ws {
+		for j := i + 1; j <= min(i+n, len(ws)); j++ {
+			g := strings.Join(ws[i:j], " ")
+			if seen[g] {
+				continue
+			}
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+
+	return grams
+}
+
+// ContainsTerm reports whether the useful words of text contain the words of
+// term, in the same order and next to each other.
+func ContainsTerm(text, term string) bool {
+	t := strings.Join(words(term), " ")
+	if t == "" {
+		return false
+	}
+	return strings.Contains(" "+strings.Join(words(text), " ")+" ", " "+t+" ")
+}
+
+// words returns all lower case words in text that are long enough and not a
+// stopword.
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	result := make([]string, 0, len(fields))
+	for _, w := range fields {
+		if len([]rune(w)) < 3 || stopwords[w] {
+			continue
+		}
+		result = append(result, w)
+	}
+
+	return result
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+var errBadRequest = errors.New("bad request")
+
+// API serves the curated entries, the feeds and the ratings as JSON. Every
+// request needs the token in an Authorization: Bearer header.
+type API struct {
+	miniflux *source.Miniflux
+	service  *storage.ServiceRepo
+	tui      *storage.TuiRepo
+	cli      *storage.CliRepo
+	token    string
+	logger   *slog.Logger
+
+	scorersMu sync.Mutex
+	scorers   map[int64]editionScorer
+}
+
+// editionScorer is the scorer that was trained for an edition, so the model
+// is only trained again when the service made a new one.
+type editionScorer struct {
+	editionID int64
+	scorer    scoring.Scorer
+}
+
+func NewAPI(mf *source.Miniflux, service *storage.ServiceRepo, tui *storage.TuiRepo, cli *storage.CliRepo, token string, logger *slog.Logger) *API {
+	return &API{
+		miniflux: mf,
+		service:  service,
+		tui:      tui,
+		cli:      cli,
+		token:    token,
+		logger:   logger,
+		scorers:  make(map[int64]editionScorer),
+	}
+}
+
+type categoryJSON struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feedJSON struct {
+	ID         int64  `json:"id"`
+	CategoryID int64  `json:"category_id"`
+	Title      string `json:"title"`
+	SiteURL    string `json:"site_url"`
+	FeedURL    string `json:"feed_url"`
+}
+
+type entryJSON struct {
+	ID        int64     `json:"id"`
+	FeedID    int64     `json:"feed_id"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Content   string    `json:"content"`
+	Published time.Time `json:"published"`
+	Score     float64   `json:"score"`
+}
+
+type ratingJSON struct {
+	Rating string `json:"rating"`
+}
+
+type summaryJSON struct {
+	CategoryID int64            `json:"category_id"`
+	Category   string           `json:"category"`
+	Ratings    map[string]int64 `json:"ratings"`
+	Total      int64            `json:"total"`
+}
+
+func (api *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/categories", api.handle(api.categories))
+	mux.HandleFunc("GET /api/feeds", api.handle(api.feeds))
+	mux.HandleFunc("GET /api/entries", api.handle(api.entries))
+	mux.HandleFunc("POST /api/entries/{id}/rating", api.handle(api.rate))
+	mux.HandleFunc("GET /api/summary", api.handle(api.summary))
+
+	return mux
+}
+
+// handle checks the token, writes the result of h as JSON and turns errors
+// into a JSON error message.
+func (api *API) handle(h func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !api.authorized(r) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		result, err := h(r)
+		switch {
+		case errors.Is(err, errBadRequest):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case err != nil:
+			api.logger.Error("api request failed", "path", r.URL.Path, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+	}
+}
+
+func (api *API) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(api.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (api *API) categories(_ *http.Request) (any, error) {
+	cats, err := api.tui.Categories()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]categoryJSON, 0, len(cats))
+	for _, c := range cats {
+		result = append(result, categoryJSON{ID: c.ID, Title: c.Title})
+	}
+
+	return result, nil
+}
+
+func (api *API) feeds(_ *http.Request) (any, error) {
+	feeds, err := api.tui.Feeds()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]feedJSON, 0, len(feeds))
+	for _, f := range feeds {
+		result = append(result, feedJSON{
+			ID:         f.ID,
+			CategoryID: f.CategoryID,
+			Title:      f.Title,
+			SiteURL:    f.SiteURL,
+			FeedURL:    f.FeedURL,
+		})
+	}
+
+	return result, nil
+}
+
+// entries lists the unread entries of the current edition of a category,
+// best score first.
+func (api *API) entries(r *http.Request) (any, error) {
+	categoryID, err := strconv.ParseInt(r.URL.Query().Get("category"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid category", errBadRequest)
+	}
+
+	return api.curated(categoryID)
+}
+
+func (api *API) curated(categoryID int64) ([]entryJSON, error) {
+	entries, err := api.miniflux.Unread(categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if entries, err = api.service.WithExtracted(entries); err != nil {
+		return nil, err
+	}
+	edition, err := api.service.CurrentEdition(categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if edition != nil {
+		entries = slices.DeleteFunc(entries, func(e domain.Entry) bool {
+			return !slices.Contains(edition.EntryIDs, e.ID)
+		})
+	}
+	scorer, err := api.scorer(categoryID, edition)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]entryJSON, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, entryJSON{
+			ID:        e.ID,
+			FeedID:    e.FeedID,
+			Title:     e.Title,
+			URL:       e.URL,
+			Content:   e.Content,
+			Published: e.Published,
+			Score:     scorer.Score(e, categoryID, now).Score,
+		})
+	}
+	slices.SortStableFunc(result, func(a, b entryJSON) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	return result, nil
+}
+
+// scorer returns the scorer for the current edition of the category. It is
+// trained on the first request after the edition changed and kept until the
+// next one.
+func (api *API) scorer(categoryID int64, edition *domain.Edition) (scoring.Scorer, error) {
+	var editionID int64
+	if edition != nil {
+		editionID = edition.ID
+	}
+
+	api.scorersMu.Lock()
+	defer api.scorersMu.Unlock()
+	if cached, ok := api.scorers[categoryID]; ok && cached.editionID == editionID {
+		return cached.scorer, nil
+	}
+
+	rated, err := api.service.RatedEntries()
+	if err != nil {
+		return scoring.Scorer{}, err
+	}
+	stored, err := api.service.Rules()
+	if err != nil {
+		return scoring.Scorer{}, err
+	}
+	scorer := scoring.Scorer{Model: scoring.Train(rated), Rules: scoring.DefaultRules.With(stored)}
+	api.scorers[categoryID] = editionScorer{editionID: editionID, scorer: scorer}
+
+	return scorer, nil
+}
+
+func (api *API) rate(r *http.Request) (any, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid entry id", errBadRequest)
+	}
+	var body ratingJSON
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: invalid body", errBadRequest)
+	}
+	if err := api.rateEntry(id, body.Rating); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// userRatings returns the ratings that can be given, all values of the
+// rating enum except the weak label for the imported history.
+func (api *API) userRatings() ([]string, error) {
+	values, err := api.tui.RatingValues()
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(values, func(v string) bool { return v == "weak_read" }), nil
+}
+
+// rateEntry stores the rating and marks the entry read in Miniflux, like
+// rating in the TUI does.
+func (api *API) rateEntry(id int64, rating string) error {
+	known, err := api.userRatings()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(known, rating) {
+		return fmt.Errorf("%w: unknown rating %q, choose from %s", errBadRequest, rating, strings.Join(known, ", "))
+	}
+
+	entry, _, err := api.miniflux.Entry(id)
+	if err != nil {
+		return fmt.Errorf("could not fetch entry: %v", err)
+	}
+	entries, err := api.service.WithExtracted([]domain.Entry{entry})
+	if err != nil {
+		return err
+	}
+	entry = entries[0]
+	if err := api.tui.StoreEntry(entry, rating, time.Now()); err != nil {
+		return err
+	}
+
+	return api.miniflux.MarkRead(id)
+}
+
+// summary returns the category × rating matrix.
+func (api *API) summary(_ *http.Request) (any, error) {
+	matrix, err := api.cli.CategoryRatingMatrix()
+	if err != nil {
+		return nil, err
+	}
+	names, err := api.cli.CategoryNames()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]summaryJSON, 0, len(matrix))
+	for catID, ratings := range matrix {
+		var total int64
+		for _, count := range ratings {
+			total += count
+		}
+		result = append(result, summaryJSON{
+			CategoryID: catID,
+			Category:   names[catID],
+			Ratings:    ratings,
+			Total:      total,
+		})
+	}
+	slices.SortFunc(result, func(a, b summaryJSON) int {
+		return cmp.Compare(a.CategoryID, b.CategoryID)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+)
+
+const (
+	dropRead   = "read"
+	dropSnooze = "snooze"
+
+	extractReadability = "readability"
+	extractMiniflux    = "miniflux"
+)
+
+// Config holds the selection and schedule settings per category. It is read
+// from the TOML file in CONFIG_PATH, for example:
+//
+//	[[category]]
+//	id = 6
+//	min = 5
+//	max = 10
+//	weekend_max = 20
+//	always_feeds = [42]
+//	feed_daily_cap = 4
+//	feed_cap = 2
+//	host_cap = 3
+//	diversity = 0.3
+//	times = ["07:00", "18:00"]
+//	jitter = "5m"
+//
+//	[[category]]
+//	id = 3
+//	interval = "30m"
+//	quiet_hours = "23:00-07:00"
+//	drop = "snooze"
+//	resurface_score = 0.6
+//	extract = "readability"
+//
+// Without a file the defaults below are used. A max of 0 keeps all entries
+// that are not skipped by a rule. See scoring.Constraints for how the budgets
+// and caps are applied and Schedule for the schedule settings.
+//
+// Entries that are not selected are marked read. With drop = "snooze" they
+// are put aside instead, and the best of them, with at least the
+// resurface_score, fill up later editions that have room.
+//
+// With extract set, entries with little content get the full article from
+// their page, either with the built-in readability extractor or with the
+// scraper of Miniflux.
+type Config struct {
+	Categories []CategoryConfig `toml:"category"`
+}
+
+type CategoryConfig struct {
+	ID        int64   `toml:"id"`
+	FeedCap   int     `toml:"feed_cap"`
+	HostCap   int     `toml:"host_cap"`
+	Diversity float64 `toml:"diversity"`
+
+	// Keep is the old name of Max
+	Keep         int     `toml:"keep"`
+	Min          int     `toml:"min"`
+	Max          int     `toml:"max"`
+	WeekdayMax   int     `toml:"weekday_max"`
+	WeekendMax   int     `toml:"weekend_max"`
+	FeedDailyCap int     `toml:"feed_daily_cap"`
+	AlwaysFeeds  []int64 `toml:"always_feeds"`
+
+	Drop           string  `toml:"drop"`
+	ResurfaceScore float64 `toml:"resurface_score"`
+	Extract        string  `toml:"extract"`
+
+	Interval   string   `toml:"interval"`
+	Cron       string   `toml:"cron"`
+	Times      []string `toml:"times"`
+	QuietHours string   `toml:"quiet_hours"`
+	Jitter     string   `toml:"jitter"`
+
+	schedule Schedule
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Categories: []CategoryConfig{
+			{ID: domain.CatVideo, schedule: Schedule{Interval: defaultInterval}},
+			{ID: domain.CatNewsAggregator, Max: 10, FeedCap: 3, HostCap: 3, Diversity: 0.3, schedule: Schedule{Interval: defaultInterval}},
+			{ID: domain.CatSmallWeb, Max: 10, FeedCap: 2, Diversity: 0.3, schedule: Schedule{Interval: defaultInterval}},
+		},
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return Config{}, err
+	}
+
+	var conf Config
+	if _, err := toml.DecodeFile(path, &conf); err != nil {
+		return Config{}, fmt.Errorf("could not read config: %v", err)
+	}
+	for i, cc := range conf.Categories {
+		if cc.Max == 0 {
+			cc.Max = cc.Keep
+			conf.Categories[i].Max = cc.Keep
+		}
+		for _, v := range []int{cc.Min, cc.Max, cc.WeekdayMax, cc.WeekendMax, cc.FeedCap, cc.HostCap, cc.FeedDailyCap} {
+			if v < 0 {
+				return Config{}, fmt.Errorf("category %d: budgets and caps can not be negative", cc.ID)
+			}
+		}
+		for _, m := range []int{cc.Max, cc.WeekdayMax, cc.WeekendMax} {
+			if m > 0 && cc.Min > m {
+				return Config{}, fmt.Errorf("category %d: min is larger than max", cc.ID)
+			}
+		}
+		if cc.Diversity < 0 || cc.Diversity > 1 {
+			return Config{}, fmt.Errorf("category %d: diversity must be between 0 and 1", cc.ID)
+		}
+		if cc.Drop != "" && cc.Drop != dropRead && cc.Drop != dropSnooze {
+			return Config{}, fmt.Errorf("category %d: drop must be %s or %s", cc.ID, dropRead, dropSnooze)
+		}
+		if cc.Extract != "" && cc.Extract != extractReadability && cc.Extract != extractMiniflux {
+			return Config{}, fmt.Errorf("category %d: extract must be %s or %s", cc.ID, extractReadability, extractMiniflux)
+		}
+		schedule, err := parseSchedule(cc.Interval, cc.Cron, cc.Times, cc.QuietHours, cc.Jitter)
+		if err != nil {
+			return Config{}, fmt.Errorf("category %d: %v", cc.ID, err)
+		}
+		conf.Categories[i].schedule = schedule
+	}
+
+	return conf, nil
+}
+
+// MaxOn returns the number of entries to keep on the day of t. The weekday
+// and weekend budgets, when set, replace the max.
+func (cc CategoryConfig) MaxOn(t time.Time) int {
+	switch t.Weekday() {
+	case time.Saturday, time.Sunday:
+		if cc.WeekendMax > 0 {
+			return cc.WeekendMax
+		}
+	default:
+		if cc.WeekdayMax > 0 {
+			return cc.WeekdayMax
+		}
+	}
+
+	return cc.Max
+}
+
+func (cc CategoryConfig) Constraints(now time.Time, keptToday map[int64]int) scoring.Constraints {
+	return scoring.Constraints{
+		Min:          cc.Min,
+		Max:          cc.MaxOn(now),
+		FeedCap:      cc.FeedCap,
+		HostCap:      cc.HostCap,
+		FeedDailyCap: cc.FeedDailyCap,
+		KeptToday:    keptToday,
+		Guaranteed:   cc.AlwaysFeeds,
+		Diversity:    cc.Diversity,
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/source"
+)
+
+// Healthz reports that the service is running.
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// Readyz reports whether Miniflux and the database can be reached, so that
+// an entry can be processed and rated.
+func Readyz(mf *source.Miniflux, db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		failures := make([]string, 0)
+		if err := mf.Healthcheck(ctx); err != nil {
+			failures = append(failures, err.Error())
+		}
+		if err := db.PingContext(ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("postgres not reachable: %v", err))
+		}
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, f := range failures {
+				fmt.Fprintln(w, f)
+			}
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	}
+}
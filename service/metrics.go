@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	metricFetched          = "arss_entries_fetched_total"
+	metricKept             = "arss_entries_kept_total"
+	metricSkipped          = "arss_entries_skipped_total"
+	metricResurfaced       = "arss_entries_resurfaced_total"
+	metricExtracted        = "arss_entries_extracted_total"
+	metricMinifluxErrors   = "arss_miniflux_errors_total"
+	metricMinifluxDuration = "arss_miniflux_request_duration_seconds"
+	metricLastSuccess      = "arss_last_success_timestamp_seconds"
+	metricRunDuration      = "arss_run_duration_seconds"
+
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+type metricDef struct {
+	typ  string
+	help string
+}
+
+var metricDefs = map[string]metricDef{
+	metricFetched:          {typeCounter, "Unread entries fetched from Miniflux."},
+	metricKept:             {typeCounter, "Entries kept unread by the selection, counted on every run."},
+	metricSkipped:          {typeCounter, "Entries marked read, by the rule, duplicate or selection that dropped them."},
+	metricResurfaced:       {typeCounter, "Snoozed entries that came back in an edition."},
+	metricExtracted:        {typeCounter, "Attempts to extract the article of an entry, by result."},
+	metricMinifluxErrors:   {typeCounter, "Failed requests to Miniflux."},
+	metricMinifluxDuration: {typeHistogram, "Duration of requests to Miniflux."},
+	metricLastSuccess:      {typeGauge, "Unix time of the last run that processed its categories without errors."},
+	metricRunDuration:      {typeGauge, "Duration of the last scheduled run."},
+}
+
+var histogramBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics keeps the service metrics and writes them in the Prometheus text
+// format. Labels are passed as name, value pairs.
+type Metrics struct {
+	mu         sync.Mutex
+	values     map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		values:     make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+func (m *Metrics) Add(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.values[name] == nil {
+		m.values[name] = make(map[string]float64)
+	}
+	m.values[name][formatLabels(labels)] += value
+}
+
+func (m *Metrics) Set(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.values[name] == nil {
+		m.values[name] = make(map[string]float64)
+	}
+	m.values[name][formatLabels(labels)] = value
+}
+
+func (m *Metrics) Observe(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.histograms[name] == nil {
+		m.histograms[name] = make(map[string]*histogram)
+	}
+	key := formatLabels(labels)
+	h, ok := m.histograms[name][key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(histogramBuckets))}
+		m.histograms[name][key] = h
+	}
+	for i, le := range histogramBuckets {
+		if value <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sb strings.Builder
+	names := make([]string, 0, len(metricDefs))
+	for name := range metricDefs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		def := metricDefs[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, def.help, name, def.typ)
+		if def.typ != typeHistogram {
+			for _, labels := range sortedKeys(m.values[name]) {
+				fmt.Fprintf(&sb, "%s%s %s\n", name, braces(labels), formatFloat(m.values[name][labels]))
+			}
+			continue
+		}
+		for _, labels := range sortedKeys(m.histograms[name]) {
+			h := m.histograms[name][labels]
+			for i, le := range histogramBuckets {
+				fmt.Fprintf(&sb, "%s_bucket%s %d\n", name, braces(joinLabels(labels, `le="`+formatFloat(le)+`"`)), h.counts[i])
+			}
+			fmt.Fprintf(&sb, "%s_bucket%s %d\n", name, braces(joinLabels(labels, `le="+Inf"`)), h.count)
+			fmt.Fprintf(&sb, "%s_sum%s %s\n", name, braces(labels), formatFloat(h.sum))
+			fmt.Fprintf(&sb, "%s_count%s %d\n", name, braces(labels), h.count)
+		}
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func formatLabels(labels []string) string {
+	parts := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, labels[i]+"="+strconv.Quote(labels[i+1]))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
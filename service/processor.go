@@ -0,0 +1,425 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+const (
+	// snoozeMaxAge is how long a snoozed entry can come back.
+	snoozeMaxAge = 14 * 24 * time.Hour
+
+	// editionRetention is how long old editions are kept. They are needed
+	// for the daily feed cap.
+	editionRetention = 7 * 24 * time.Hour
+
+	// Entries with fewer words than extractMinWords get their article
+	// extracted, at most extractPerRun per category in a run. The rest wait
+	// for the next run.
+	extractMinWords = 150
+	extractPerRun   = 20
+	extractTimeout  = 15 * time.Second
+)
+
+// Processor trims the unread entries of the configured categories down to
+// the selection. Runs are serialized, so the polling loop and the webhook
+// can not work on the same entries at the same time.
+type Processor struct {
+	miniflux    *source.Miniflux
+	readability *source.Readability
+	repo        *storage.ServiceRepo
+	conf        Config
+	metrics     *Metrics
+	logger      *slog.Logger
+	mu          sync.Mutex
+
+	pendingMu sync.Mutex
+	pending   map[int64]bool
+}
+
+func NewProcessor(mf *source.Miniflux, repo *storage.ServiceRepo, conf Config, metrics *Metrics, logger *slog.Logger) *Processor {
+	return &Processor{
+		miniflux:    mf,
+		readability: source.NewReadability(extractTimeout),
+		repo:        repo,
+		conf:        conf,
+		metrics:     metrics,
+		logger:      logger,
+		pending:     make(map[int64]bool),
+	}
+}
+
+// Run makes a new edition for the given categories, in config order. The
+// editions are valid until the time given for their category. It returns the
+// categories that could not be processed.
+func (p *Processor) Run(due map[int64]time.Time) []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.logger.Info("checking feed...", "categories", len(due))
+	start := time.Now()
+	scorer := p.scorer()
+	failed := make([]int64, 0)
+	for _, cc := range p.conf.Categories {
+		validUntil, ok := due[cc.ID]
+		if !ok {
+			continue
+		}
+		if err := p.process(cc, scorer, validUntil); err != nil {
+			p.logger.Error("could not process category", "category", cc.ID, "error", err)
+			failed = append(failed, cc.ID)
+		}
+	}
+
+	p.metrics.Set(metricRunDuration, time.Since(start).Seconds())
+	if len(failed) == 0 {
+		p.metrics.Set(metricLastSuccess, float64(time.Now().Unix()))
+	}
+
+	return failed
+}
+
+// Trigger applies the skip rules to the unread entries of a category in the
+// background. The other new entries are left for the next edition. A trigger
+// for a category that is still waiting for its turn is dropped, since that
+// run will see the new entries as well. Categories that are not in the config
+// are ignored.
+func (p *Processor) Trigger(categoryID int64) {
+	idx := slices.IndexFunc(p.conf.Categories, func(cc CategoryConfig) bool {
+		return cc.ID == categoryID
+	})
+	if idx < 0 {
+		return
+	}
+
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	if p.pending[categoryID] {
+		return
+	}
+	p.pending[categoryID] = true
+
+	go func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.pendingMu.Lock()
+		delete(p.pending, categoryID)
+		p.pendingMu.Unlock()
+
+		if err := p.process(p.conf.Categories[idx], p.scorer(), time.Time{}); err != nil {
+			p.logger.Error("could not process category", "category", categoryID, "error", err)
+		}
+	}()
+}
+
+func (p *Processor) scorer() scoring.Scorer {
+	rated, err := p.repo.RatedEntries()
+	if err != nil {
+		p.logger.Error("could not get rated entries, scoring without model", "error", err)
+	}
+	stored, err := p.repo.Rules()
+	if err != nil {
+		p.logger.Error("could not get accepted rules, using the default rules", "error", err)
+	}
+
+	return scoring.Scorer{Model: scoring.Train(rated), Rules: scoring.DefaultRules.With(stored)}
+}
+
+// observe records the duration and the outcome of a request to Miniflux.
+func (p *Processor) observe(operation string, start time.Time, err error) {
+	p.metrics.Observe(metricMinifluxDuration, time.Since(start).Seconds(), "operation", operation)
+	if err != nil {
+		p.metrics.Add(metricMinifluxErrors, 1, "operation", operation)
+	}
+}
+
+// extract replaces the content of entries that have little of it by the
+// article from their page. Every entry is tried once, failures are stored
+// as empty content and the entry keeps its feed content.
+func (p *Processor) extract(cc CategoryConfig, entries []domain.Entry) ([]domain.Entry, error) {
+	ids := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	known, err := p.repo.Extracted(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var extractor source.Extractor = p.readability
+	if cc.Extract == extractMiniflux {
+		extractor = p.miniflux
+	}
+	catLabel := strconv.FormatInt(cc.ID, 10)
+	var tried int
+	for i, e := range entries {
+		if content, ok := known[e.ID]; ok {
+			if content != "" {
+				entries[i].Content = content
+			}
+			continue
+		}
+		if len(strings.Fields(e.Content)) >= extractMinWords || tried >= extractPerRun {
+			continue
+		}
+		tried++
+
+		start := time.Now()
+		content, err := extractor.Extract(e)
+		if cc.Extract == extractMiniflux {
+			p.observe("fetch_content", start, err)
+		}
+		result := "ok"
+		if err != nil {
+			p.logger.Warn("could not extract article", "category", cc.ID, "entry", e.ID, "url", e.URL, "error", err)
+			content, result = "", "failed"
+		}
+		p.metrics.Add(metricExtracted, 1, "category", catLabel, "result", result)
+		if err := p.repo.StoreExtracted(e.ID, content, time.Now()); err != nil {
+			return nil, err
+		}
+		if content != "" {
+			entries[i].Content = content
+		}
+	}
+
+	return entries, nil
+}
+
+// resurface brings the best snoozed entries back when the edition has room
+// for them. Entries that are marked read in this run are left alone, they
+// were dropped for a reason.
+func (p *Processor) resurface(cc CategoryConfig, spare int, skipIDs []int64, now time.Time) ([]domain.Entry, error) {
+	if spare <= 0 {
+		return nil, nil
+	}
+	snoozed, err := p.repo.Snoozed(cc.ID, now.Add(-snoozeMaxAge), now, cc.ResurfaceScore, spare, skipIDs)
+	if err != nil || len(snoozed) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(snoozed))
+	entries := make([]domain.Entry, 0, len(snoozed))
+	for _, se := range snoozed {
+		ids = append(ids, se.Entry.ID)
+		entries = append(entries, se.Entry)
+	}
+	start := time.Now()
+	err = p.miniflux.MarkUnread(ids...)
+	p.observe("mark_unread", start, err)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.repo.Resurface(ids, now); err != nil {
+		return nil, err
+	}
+	p.metrics.Add(metricResurfaced, float64(len(ids)), "category", strconv.FormatInt(cc.ID, 10))
+
+	return entries, nil
+}
+
+// process marks the entries that match a skip rule read. With a validUntil,
+// it also merges duplicates, selects the entries to keep, marks the rest read
+// and stores the kept entries as a new edition. The unread entries of the
+// current edition are always kept, so an edition only changes by new entries
+// filling the room that is left.
+func (p *Processor) process(cc CategoryConfig, scorer scoring.Scorer, validUntil time.Time) error {
+	category := cc.ID
+	catLabel := strconv.FormatInt(category, 10)
+	catLogger := p.logger.With("category", category)
+
+	start := time.Now()
+	entries, err := p.miniflux.Unread(category)
+	p.observe("unread", start, err)
+	if err != nil {
+		return fmt.Errorf("could not fetch entries: %v", err)
+	}
+	if len(entries) == 0 && validUntil.IsZero() {
+		catLogger.Info("no unread entries found")
+		return nil
+	}
+
+	catLogger.Info("unread entries found", "count", len(entries))
+	p.metrics.Add(metricFetched, float64(len(entries)), "category", catLabel)
+	if cc.Extract != "" {
+		if entries, err = p.extract(cc, entries); err != nil {
+			return fmt.Errorf("could not extract articles: %v", err)
+		}
+	}
+
+	skipIDs := make([]int64, 0)
+	remaining := make([]domain.Entry, 0)
+	scores := make(map[int64]float64)
+	now := time.Now()
+	for _, entry := range entries {
+		if _, err := url.Parse(entry.URL); err != nil {
+			catLogger.Error("could not parse url", "url", entry.URL)
+			continue
+		}
+		exp := scorer.Score(entry, category, now)
+		if exp.Skip {
+			for _, r := range scorer.Rules.Matching(entry, category, now) {
+				if r.Action == scoring.ActionSkip {
+					p.metrics.Add(metricSkipped, 1, "category", catLabel, "reason", r.Name)
+					break
+				}
+			}
+			skipIDs = append(skipIDs, entry.ID)
+			continue
+		}
+		scores[entry.ID] = exp.Score
+		remaining = append(remaining, entry)
+	}
+
+	if validUntil.IsZero() {
+		if len(skipIDs) == 0 {
+			return nil
+		}
+		start = time.Now()
+		err = p.miniflux.MarkRead(skipIDs...)
+		p.observe("mark_read", start, err)
+		if err != nil {
+			return err
+		}
+		catLogger.Info("skip rules applied", "marked_read", len(skipIDs), "queued", len(remaining))
+
+		return nil
+	}
+
+	// The unread entries of the current edition stay, the new entries can
+	// only fill the room that is left.
+	current, err := p.repo.CurrentEdition(category)
+	if err != nil {
+		return err
+	}
+	carried := make(map[int64]bool)
+	if current != nil {
+		for _, entry := range remaining {
+			if slices.Contains(current.EntryIDs, entry.ID) {
+				carried[entry.ID] = true
+			}
+		}
+	}
+
+	// Of every story that appears more than once, keep the copy with the
+	// best score, or the one that is carried over. Shuffle first, so that
+	// entries with the same score get an equal chance.
+	rand.Shuffle(len(remaining), func(i, j int) {
+		remaining[i], remaining[j] = remaining[j], remaining[i]
+	})
+	slices.SortStableFunc(remaining, func(a, b domain.Entry) int {
+		if carried[a.ID] != carried[b.ID] {
+			if carried[a.ID] {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(scores[b.ID], scores[a.ID])
+	})
+	remaining, dups := scoring.Cluster(remaining, scoring.DuplicateDistance)
+	if len(dups) > 0 {
+		if err := p.repo.AddDuplicates(category, dups, now); err != nil {
+			catLogger.Error("could not record duplicates", "error", err)
+		}
+		for _, d := range dups {
+			skipIDs = append(skipIDs, d.Entry.ID)
+		}
+		catLogger.Info("duplicates merged", "count", len(dups))
+		p.metrics.Add(metricSkipped, float64(len(dups)), "category", catLabel, "reason", "duplicate")
+	}
+
+	candidates := make([]scoring.Candidate, 0, len(remaining))
+	for _, entry := range remaining {
+		candidates = append(candidates, scoring.Candidate{Entry: entry, Score: scores[entry.ID], Keep: carried[entry.ID]})
+	}
+	keptToday := make(map[int64]int)
+	if cc.FeedDailyCap > 0 {
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if keptToday, err = p.repo.KeptSince(category, midnight); err != nil {
+			return err
+		}
+		// Carried entries are counted by the selection itself.
+		if current != nil && !current.Created.Before(midnight) {
+			for _, entry := range remaining {
+				if carried[entry.ID] {
+					keptToday[entry.FeedID]--
+				}
+			}
+		}
+	}
+	constraints := cc.Constraints(now, keptToday)
+	kept, dropped := scoring.Select(candidates, constraints)
+	for _, entry := range dropped {
+		skipIDs = append(skipIDs, entry.ID)
+	}
+	p.metrics.Add(metricKept, float64(len(kept)), "category", catLabel)
+	if len(dropped) > 0 {
+		p.metrics.Add(metricSkipped, float64(len(dropped)), "category", catLabel, "reason", "selection")
+	}
+	if cc.Drop == dropSnooze {
+		snoozed := make([]domain.SnoozedEntry, 0, len(dropped))
+		for _, entry := range dropped {
+			snoozed = append(snoozed, domain.SnoozedEntry{
+				Entry:      entry,
+				CategoryID: category,
+				Score:      scores[entry.ID],
+				Snoozed:    now,
+			})
+		}
+		if err := p.repo.Snooze(snoozed); err != nil {
+			return err
+		}
+		resurfaced, err := p.resurface(cc, constraints.Max-len(kept), skipIDs, now)
+		if err != nil {
+			catLogger.Error("could not resurface snoozed entries", "error", err)
+		}
+		kept = append(kept, resurfaced...)
+	}
+
+	// Mark all rule-matching entries, duplicates and dropped entries as read
+	if len(skipIDs) > 0 {
+		start = time.Now()
+		err = p.miniflux.MarkRead(skipIDs...)
+		p.observe("mark_read", start, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	edition := domain.Edition{
+		CategoryID: category,
+		Created:    now,
+		ValidUntil: validUntil,
+		EntryIDs:   make([]int64, 0, len(kept)),
+	}
+	feedIDs := make(map[int64]int64, len(kept))
+	for _, entry := range kept {
+		edition.EntryIDs = append(edition.EntryIDs, entry.ID)
+		feedIDs[entry.ID] = entry.FeedID
+	}
+	id, err := p.repo.AddEdition(edition, feedIDs)
+	if err != nil {
+		return err
+	}
+	if err := p.repo.PruneEditions(category, id, now.Add(-editionRetention)); err != nil {
+		catLogger.Error("could not prune old editions", "error", err)
+	}
+
+	catLogger.Info("entries processed", "edition", id, "kept", len(kept), "carried", len(carried), "marked_read", len(skipIDs))
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultInterval = 10 * time.Minute
+
+// Schedule says when a category is processed: every interval, on a cron
+// expression or at fixed times of the day. Runs that would fall in the quiet
+// hours are moved to the end of them. Jitter delays every run by a random
+// duration up to its value.
+type Schedule struct {
+	Interval time.Duration
+	Cron     *cronExpr
+	Times    []int
+	Quiet    *window
+	Jitter   time.Duration
+}
+
+// Next returns the first run after the given time.
+func (s Schedule) Next(after time.Time) time.Time {
+	var next time.Time
+	switch {
+	case s.Cron != nil:
+		next = s.Cron.next(after)
+	case len(s.Times) > 0:
+		next = nextTime(s.Times, after)
+	default:
+		next = after.Add(s.Interval)
+	}
+	if s.Jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(s.Jitter))))
+	}
+	if s.Quiet != nil {
+		next = s.Quiet.after(next)
+	}
+
+	return next
+}
+
+// parseSchedule reads the schedule settings of a category. Only one of
+// interval, cron and times can be set. Without any, the category runs every
+// ten minutes.
+func parseSchedule(interval, cron string, times []string, quiet, jitter string) (Schedule, error) {
+	s := Schedule{Interval: defaultInterval}
+	set := 0
+	if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d < time.Minute {
+			return Schedule{}, fmt.Errorf("invalid interval %q, use a duration of at least a minute", interval)
+		}
+		s.Interval = d
+		set++
+	}
+	if cron != "" {
+		c, err := parseCron(cron)
+		if err != nil {
+			return Schedule{}, err
+		}
+		s.Cron = c
+		set++
+	}
+	if len(times) > 0 {
+		for _, t := range times {
+			m, err := parseTimeOfDay(t)
+			if err != nil {
+				return Schedule{}, err
+			}
+			s.Times = append(s.Times, m)
+		}
+		set++
+	}
+	if set > 1 {
+		return Schedule{}, fmt.Errorf("use only one of interval, cron and times")
+	}
+	if quiet != "" {
+		w, err := parseWindow(quiet)
+		if err != nil {
+			return Schedule{}, err
+		}
+		s.Quiet = w
+	}
+	if jitter != "" {
+		d, err := time.ParseDuration(jitter)
+		if err != nil || d < 0 {
+			return Schedule{}, fmt.Errorf("invalid jitter %q", jitter)
+		}
+		s.Jitter = d
+	}
+
+	return s, nil
+}
+
+// parseTimeOfDay returns the minutes since midnight of a time like 07:30.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, use hh:mm", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func atMinute(day time.Time, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, day.Location())
+}
+
+func nextTime(times []int, after time.Time) time.Time {
+	var next time.Time
+	for offset := range 2 {
+		day := after.AddDate(0, 0, offset)
+		for _, m := range times {
+			t := atMinute(day, m)
+			if t.After(after) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+		if !next.IsZero() {
+			break
+		}
+	}
+
+	return next
+}
+
+// window is a period of the day, that may cross midnight, like 23:00-07:00.
+type window struct {
+	start int
+	end   int
+}
+
+func parseWindow(s string) (*window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid quiet hours %q, use hh:mm-hh:mm", s)
+	}
+	start, err := parseTimeOfDay(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeOfDay(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &window{start: start, end: end}, nil
+}
+
+// after returns t, or the end of the window when t falls inside it.
+func (w *window) after(t time.Time) time.Time {
+	m := t.Hour()*60 + t.Minute()
+	switch {
+	case w.start <= w.end && m >= w.start && m < w.end:
+		return atMinute(t, w.end)
+	case w.start > w.end && m >= w.start:
+		return atMinute(t.AddDate(0, 0, 1), w.end)
+	case w.start > w.end && m < w.end:
+		return atMinute(t, w.end)
+	default:
+		return t
+	}
+}
+
+// cronExpr is a standard five field cron expression: minute, hour, day of
+// month, month and day of week. Fields support *, lists, ranges and steps.
+type cronExpr struct {
+	minute, hour, dom, month, dow []bool
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (*cronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q, need five fields", expr)
+	}
+	c := &cronExpr{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	for i, f := range []struct {
+		dst      *[]bool
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	} {
+		set, err := parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		*f.dst = set
+	}
+	// both 0 and 7 are sunday
+	c.dow[0] = c.dow[0] || c.dow[7]
+
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+func (c *cronExpr) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (c *cronExpr) next(after time.Time) time.Time {
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, after.Location()).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	// an expression like 0 0 31 2 * never matches
+	return limit
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+const (
+	checkInterval = time.Minute
+	retryDelay    = 5 * time.Minute
+)
+
+// Scheduler keeps track of when each category is due. The runs are stored,
+// so a restart does not reset the schedule and a run that was missed while
+// the service was down is done right away.
+type Scheduler struct {
+	repo   *storage.ServiceRepo
+	conf   Config
+	runs   map[int64]storage.ScheduleRun
+	logger *slog.Logger
+}
+
+func NewScheduler(repo *storage.ServiceRepo, conf Config, now time.Time, logger *slog.Logger) (*Scheduler, error) {
+	runs, err := repo.ScheduleRuns()
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{
+		repo:   repo,
+		conf:   conf,
+		runs:   runs,
+		logger: logger,
+	}
+	for _, cc := range conf.Categories {
+		if _, ok := s.runs[cc.ID]; ok {
+			continue
+		}
+		s.update(cc, storage.ScheduleRun{NextRun: cc.schedule.Next(now)})
+	}
+
+	return s, nil
+}
+
+// Due returns the categories that should run now, with the time of the run
+// after that, which is when their new edition ends.
+func (s *Scheduler) Due(now time.Time) map[int64]time.Time {
+	due := make(map[int64]time.Time)
+	for _, cc := range s.conf.Categories {
+		if !s.runs[cc.ID].NextRun.After(now) {
+			due[cc.ID] = cc.schedule.Next(now)
+		}
+	}
+
+	return due
+}
+
+// Done stores the planned runs for the categories that ran. Failed
+// categories are tried again after a short delay.
+func (s *Scheduler) Done(due map[int64]time.Time, failed []int64, now time.Time) {
+	for _, cc := range s.conf.Categories {
+		next, ok := due[cc.ID]
+		switch {
+		case !ok:
+		case slices.Contains(failed, cc.ID):
+			s.update(cc, storage.ScheduleRun{LastRun: s.runs[cc.ID].LastRun, NextRun: now.Add(retryDelay)})
+		default:
+			s.update(cc, storage.ScheduleRun{LastRun: now, NextRun: next})
+		}
+	}
+}
+
+func (s *Scheduler) update(cc CategoryConfig, run storage.ScheduleRun) {
+	s.runs[cc.ID] = run
+	if err := s.repo.StoreScheduleRun(cc.ID, run); err != nil {
+		s.logger.Error("could not store schedule", "category", cc.ID, "error", err)
+	}
+	s.logger.Info("next run scheduled", "category", cc.ID, "next_run", run.NextRun.Local().Format(time.DateTime))
+}
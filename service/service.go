@@ -1,49 +1,106 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log/slog"
-	"math/rand"
-	"net/url"
+	"net/http"
 	"os"
-	"strings"
 	"time"
 
-	"go-mod.ewintr.nl/algorithmic-rss/domain"
-	miniflux "miniflux.app/v2/client"
-)
-
-var (
-	TimeoutSmallWeb        = 48 * time.Hour
-	TimeoutVids            = 21 * 24 * time.Hour
-	TimeoutAggr            = 24 * time.Hour
-	KeepEntriesPerCategory = 10
+	"go-mod.ewintr.nl/algorithmic-rss/source"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
 )
 
 func main() {
-	hostname, ok := os.LookupEnv("MINIFLUX_HOSTNAME")
-	if !ok {
-		fmt.Println("MINIFLUX_HOSTNAME not set")
+	env := make(map[string]string)
+	for _, name := range []string{
+		"MINIFLUX_HOSTNAME", "MINIFLUX_API_KEY",
+		"POSTGRES_HOSTNAME", "POSTGRES_PORT", "POSTGRES_DB_NAME", "POSTGRES_USER", "POSTGRES_PASSWORD",
+	} {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			fmt.Printf("%s not set\n", name)
+			os.Exit(1)
+		}
+		env[name] = value
+	}
+
+	mf := source.NewMiniflux(env["MINIFLUX_HOSTNAME"], env["MINIFLUX_API_KEY"])
+	pqClient, err := storage.NewClient(&storage.Config{
+		PGHostname: env["POSTGRES_HOSTNAME"],
+		PGPort:     env["POSTGRES_PORT"],
+		PGDBName:   env["POSTGRES_DB_NAME"],
+		PGUser:     env["POSTGRES_USER"],
+		PGPassword: env["POSTGRES_PASSWORD"],
+	})
+	if err != nil {
+		fmt.Printf("could not open postgres db: %s\n", err.Error())
 		os.Exit(1)
 	}
-	apiKey, ok := os.LookupEnv("MINIFLUX_API_KEY")
-	if !ok {
-		fmt.Println("MINIFLUX_API_KEY not set")
+	defer pqClient.Close()
+	repo := storage.NewServiceRepo(pqClient.DB())
+
+	conf, err := LoadConfig(os.Getenv("CONFIG_PATH"))
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	ctx := context.Background()
-	client := miniflux.NewClient(hostname, apiKey)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	logger.Info("starting service")
 
-	ticker := time.NewTicker(10 * time.Minute)
+	tuiRepo := storage.NewTuiRepo(pqClient.DB())
+	if err := mf.SyncTo(tuiRepo); err != nil {
+		logger.Error("could not sync categories and feeds", "error", err)
+	}
+
+	metrics := NewMetrics()
+	processor := NewProcessor(mf, repo, conf, metrics, logger)
+
+	if addr, ok := os.LookupEnv("LISTEN_ADDR"); ok {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics)
+		mux.Handle("GET /healthz", Healthz())
+		mux.Handle("GET /readyz", Readyz(mf, pqClient.DB()))
+		if token := os.Getenv("API_TOKEN"); token != "" {
+			api := NewAPI(mf, repo, tuiRepo, storage.NewCliRepo(pqClient.DB()), token, logger)
+			mux.Handle("/api/", api.Handler())
+			mux.Handle("/web/", NewWeb(api, conf).Handler())
+		} else {
+			logger.Info("API_TOKEN not set, api and web interface disabled")
+		}
+		if secret := os.Getenv("MINIFLUX_WEBHOOK_SECRET"); secret != "" {
+			mux.Handle("/webhook/miniflux", NewWebhook(processor, secret, logger))
+		}
+		go func() {
+			logger.Info("starting api", "addr", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				logger.Error("api stopped", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	scheduler, err := NewScheduler(repo, conf, time.Now(), logger)
+	if err != nil {
+		fmt.Printf("could not load schedule: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	ticker := time.NewTicker(checkInterval)
 	c := make(chan os.Signal, 1)
 	for {
 		select {
-		case <-ticker.C:
-			checkUnread(ctx, client, logger)
+		case now := <-ticker.C:
+			due := scheduler.Due(now)
+			if len(due) == 0 {
+				continue
+			}
+			if err := mf.SyncTo(tuiRepo); err != nil {
+				logger.Error("could not sync categories and feeds", "error", err)
+			}
+			failed := processor.Run(due)
+			scheduler.Done(due, failed, time.Now())
 		case <-c:
 			logger.Info("stopping service")
 			goto EXIT
@@ -54,96 +111,3 @@ EXIT:
 	ticker.Stop()
 	logger.Info("service exited")
 }
-
-func checkUnread(ctx context.Context, client *miniflux.Client, logger *slog.Logger) {
-	logger.Info("checking feed...")
-
-	for _, category := range []int64{domain.CatVideo, domain.CatNewsAggregator, domain.CatSmallWeb} {
-		catLogger := logger.With("category", category)
-		result, err := client.CategoryEntriesContext(ctx, category, &miniflux.Filter{Statuses: []string{"unread"}})
-		if err != nil {
-			catLogger.Error("could not fetch entries", "error", err)
-			continue
-		}
-		if result.Total == 0 {
-			catLogger.Info("no unread entries found")
-			continue
-		}
-
-		catLogger.Info("unread entries found", "count", result.Total)
-
-		// Collect all entry IDs
-		allEntryIDs := make([]int64, result.Total)
-		for i, entry := range result.Entries {
-			allEntryIDs[i] = entry.ID
-		}
-
-		skipIDs := make([]int64, 0)
-		remainingIDs := make([]int64, 0)
-
-		for _, entry := range result.Entries {
-			link, err := url.Parse(entry.URL)
-			if err != nil {
-				catLogger.Error("could not parse url", "url", entry.URL)
-				continue
-			}
-			var shouldSkip bool
-
-			switch category {
-			case domain.CatVideo:
-				if link.Hostname() == "www.youtube.com" && strings.HasPrefix(link.Path, "/shorts") {
-					shouldSkip = true
-				}
-				if link.Hostname() == "cdn.media.ccc.de" && strings.Contains(link.Path, "-deu-") {
-					shouldSkip = true
-				}
-				if time.Since(entry.Date) > TimeoutVids {
-					shouldSkip = true
-				}
-				// case domain.CatNewsAggregator:
-				// 	if time.Since(entry.Date) > TimeoutAggr {
-				// 		shouldSkip = true
-				// 	}
-				// case domain.CatSmallWeb:
-				// 	if time.Since(entry.Date) > TimeoutSmallWeb {
-				// 		shouldSkip = true
-				// }
-			}
-
-			if shouldSkip {
-				skipIDs = append(skipIDs, entry.ID)
-			} else {
-				remainingIDs = append(remainingIDs, entry.ID)
-			}
-		}
-
-		// Pick ten random entries from remainingIDs to keep unread
-		var keepIDs []int64
-		switch category {
-		case domain.CatVideo:
-			keepIDs = make([]int64, len(remainingIDs))
-			copy(keepIDs, remainingIDs)
-			remainingIDs = make([]int64, 0)
-		default:
-			keepIDs = make([]int64, 0, KeepEntriesPerCategory)
-			for i := 0; i < KeepEntriesPerCategory && len(remainingIDs) > 0; i++ {
-				idx := rand.Intn(len(remainingIDs))
-				keepIDs = append(keepIDs, remainingIDs[idx])
-				remainingIDs = append(remainingIDs[:idx], remainingIDs[idx+1:]...)
-			}
-		}
-
-		// Mark all rule-matching entries plus remainingIDs as read
-		skipIDs = append(skipIDs, remainingIDs...)
-		if len(skipIDs) == 0 {
-			catLogger.Info("all entries will be kept", "count", len(keepIDs))
-			continue
-		}
-		if err := client.UpdateEntries(skipIDs, "read"); err != nil {
-			catLogger.Error("could not mark entries read", "error", err)
-			continue
-		}
-
-		catLogger.Info("entries processed", "kept", len(keepIDs), "marked_read", len(skipIDs))
-	}
-}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+const tokenCookie = "arss_token"
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var webTemplates = template.Must(template.New("web").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f", f*100) },
+}).ParseFS(templateFS, "templates/*.html"))
+
+type ratingButton struct {
+	Value string
+	Label string
+}
+
+// ratingButtons makes a button for every rating, labelled after its value.
+func ratingButtons(ratings []string) []ratingButton {
+	buttons := make([]ratingButton, 0, len(ratings))
+	for _, r := range ratings {
+		label := strings.ReplaceAll(r, "_", " ")
+		buttons = append(buttons, ratingButton{Value: r, Label: strings.ToUpper(label[:1]) + label[1:]})
+	}
+
+	return buttons
+}
+
+type tab struct {
+	ID    int64
+	Title string
+}
+
+// Web is a small HTML interface to rate the kept entries from a phone. It
+// works like the TUI: pick a category, open an entry, rate it. Logging in
+// stores the API token in a cookie.
+type Web struct {
+	api        *API
+	categories []int64
+}
+
+func NewWeb(api *API, conf Config) *Web {
+	cats := make([]int64, 0, len(conf.Categories))
+	for _, cc := range conf.Categories {
+		cats = append(cats, cc.ID)
+	}
+
+	return &Web{api: api, categories: cats}
+}
+
+func (web *Web) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /web/login", web.loginForm)
+	mux.HandleFunc("POST /web/login", web.login)
+	mux.HandleFunc("GET /web/{$}", web.auth(web.index))
+	mux.HandleFunc("GET /web/category/{id}", web.auth(web.category))
+	mux.HandleFunc("GET /web/entry/{id}", web.auth(web.entry))
+	mux.HandleFunc("POST /web/entry/{id}/rating", web.auth(web.rate))
+
+	return mux
+}
+
+func (web *Web) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(tokenCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(web.api.token)) != 1 {
+			http.Redirect(w, r, "/web/login", http.StatusSeeOther)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (web *Web) loginForm(w http.ResponseWriter, r *http.Request) {
+	web.render(w, "login", map[string]any{})
+}
+
+func (web *Web) login(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(web.api.token)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		web.render(w, "login", map[string]any{"Error": "Invalid token"})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookie,
+		Value:    token,
+		Path:     "/web/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/web/", http.StatusSeeOther)
+}
+
+func (web *Web) index(w http.ResponseWriter, r *http.Request) {
+	if len(web.categories) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/web/category/%d", web.categories[0]), http.StatusSeeOther)
+}
+
+func (web *Web) category(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := web.page(categoryID)
+	if err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	entries, err := web.api.curated(categoryID)
+	if err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	data["Entries"] = entries
+
+	web.render(w, "category", data)
+}
+
+func (web *Web) entry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	entry, categoryID, err := web.api.miniflux.Entry(id)
+	if err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	entries, err := web.api.service.WithExtracted([]domain.Entry{entry})
+	if err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	entry = entries[0]
+	data, err := web.page(categoryID)
+	if err != nil {
+		web.fail(w, r, err)
+		return
+	}
+
+	var content bytes.Buffer
+	if err := goldmark.Convert([]byte(entry.Content), &content); err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	ratings, err := web.api.userRatings()
+	if err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	data["Entry"] = entry
+	data["Content"] = template.HTML(content.String())
+	data["Ratings"] = ratingButtons(ratings)
+
+	web.render(w, "entry", data)
+}
+
+func (web *Web) rate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := web.api.rateEntry(id, r.FormValue("rating")); err != nil {
+		web.fail(w, r, err)
+		return
+	}
+	categoryID, err := strconv.ParseInt(r.FormValue("category"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/web/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/web/category/%d", categoryID), http.StatusSeeOther)
+}
+
+// page returns the data every page needs: the category tabs and the feed
+// names.
+func (web *Web) page(categoryID int64) (map[string]any, error) {
+	cats, err := web.api.tui.Categories()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(cats))
+	for _, c := range cats {
+		names[c.ID] = c.Title
+	}
+	tabs := make([]tab, 0, len(web.categories))
+	for _, id := range web.categories {
+		tabs = append(tabs, tab{ID: id, Title: names[id]})
+	}
+
+	feeds, err := web.api.tui.Feeds()
+	if err != nil {
+		return nil, err
+	}
+	feedNames := make(map[int64]string, len(feeds))
+	for _, f := range feeds {
+		feedNames[f.ID] = f.Title
+	}
+
+	return map[string]any{
+		"Tabs":       tabs,
+		"CategoryID": categoryID,
+		"FeedNames":  feedNames,
+	}, nil
+}
+
+func (web *Web) render(w http.ResponseWriter, name string, data map[string]any) {
+	var buf bytes.Buffer
+	if err := webTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		web.api.logger.Error("could not render page", "page", name, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (web *Web) fail(w http.ResponseWriter, r *http.Request, err error) {
+	web.api.logger.Error("web request failed", "path", r.URL.Path, "error", err)
+	http.Error(w, "something went wrong, try again", http.StatusInternalServerError)
+}
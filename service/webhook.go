@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+const maxWebhookBody = 10 << 20
+
+// Webhook receives the events Miniflux sends when it finds new entries, so
+// they are trimmed right away instead of at the next poll. The body is
+// signed with the secret that Miniflux shows in the integration settings.
+type Webhook struct {
+	processor *Processor
+	secret    string
+	logger    *slog.Logger
+}
+
+func NewWebhook(processor *Processor, secret string, logger *slog.Logger) *Webhook {
+	return &Webhook{
+		processor: processor,
+		secret:    secret,
+		logger:    logger,
+	}
+}
+
+type webhookEvent struct {
+	EventType string `json:"event_type"`
+	Feed      struct {
+		ID         int64 `json:"id"`
+		CategoryID int64 `json:"category_id"`
+		Category   *struct {
+			ID int64 `json:"id"`
+		} `json:"category"`
+	} `json:"feed"`
+	Entries []struct {
+		ID int64 `json:"id"`
+	} `json:"entries"`
+}
+
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !wh.valid(body, r.Header.Get("X-Miniflux-Signature")) {
+		wh.logger.Error("webhook with invalid signature")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event webhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		wh.logger.Error("could not decode webhook", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if event.EventType != "new_entries" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	categoryID := event.Feed.CategoryID
+	if event.Feed.Category != nil {
+		categoryID = event.Feed.Category.ID
+	}
+	wh.logger.Info("new entries received", "category", categoryID, "feed", event.Feed.ID, "count", len(event.Entries))
+	wh.processor.Trigger(categoryID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wh *Webhook) valid(body []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(wh.secret))
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
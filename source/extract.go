@@ -0,0 +1,218 @@
+package source
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxPageSize      = 5 << 20
+	minParagraphSize = 25
+	minArticleSize   = 250
+)
+
+var (
+	ErrNoContent = errors.New("no article content found")
+
+	positiveHint = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text`)
+	negativeHint = regexp.MustCompile(`(?i)ad-|advert|banner|comment|cookie|footer|masthead|menu|meta|nav|newsletter|promo|related|share|sidebar|social|sponsor|subscribe|widget`)
+
+	skipTags = map[atom.Atom]bool{
+		atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+		atom.Form: true, atom.Nav: true, atom.Header: true, atom.Footer: true,
+		atom.Aside: true, atom.Svg: true, atom.Button: true, atom.Input: true,
+	}
+)
+
+// Extractor gets the full article of an entry as markdown, for feeds that
+// only publish a summary.
+type Extractor interface {
+	Extract(entry domain.Entry) (string, error)
+}
+
+// Readability downloads the page of an entry and keeps the part that looks
+// most like the article: the element with the most paragraph text, with
+// less weight for links and for class names like comment or sidebar.
+type Readability struct {
+	Client *http.Client
+}
+
+func NewReadability(timeout time.Duration) *Readability {
+	return &Readability{
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (r *Readability) Extract(entry domain.Entry) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, entry.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "algorithmic-rss")
+	res, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch page: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not fetch page: %s", res.Status)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return "", fmt.Errorf("not an html page: %s", ct)
+	}
+
+	content, err := ExtractArticle(io.LimitReader(res.Body, maxPageSize))
+	if err != nil {
+		return "", err
+	}
+
+	return ConvertHTMLToMarkdown(content), nil
+}
+
+// ExtractArticle returns the html of the main content of the page.
+func ExtractArticle(page io.Reader) (string, error) {
+	doc, err := html.Parse(page)
+	if err != nil {
+		return "", fmt.Errorf("could not parse page: %v", err)
+	}
+	clean(doc)
+
+	scores := make(map[*html.Node]float64)
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode || (n.DataAtom != atom.P && n.DataAtom != atom.Pre) {
+			continue
+		}
+		text := strings.TrimSpace(textOf(n))
+		if len(text) < minParagraphSize {
+			continue
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		if parent := n.Parent; parent != nil && parent.Type == html.ElementNode {
+			if _, ok := scores[parent]; !ok {
+				scores[parent] = initialScore(parent)
+			}
+			scores[parent] += score
+			if grand := parent.Parent; grand != nil && grand.Type == html.ElementNode {
+				if _, ok := scores[grand]; !ok {
+					scores[grand] = initialScore(grand)
+				}
+				scores[grand] += score / 2
+			}
+		}
+	}
+
+	var best *html.Node
+	var bestScore float64
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil || len(strings.TrimSpace(textOf(best))) < minArticleSize {
+		return "", ErrNoContent
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, best); err != nil {
+		return "", fmt.Errorf("could not render article: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+// clean removes the elements that are never part of the article.
+func clean(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && unlikely(c)) {
+			n.RemoveChild(c)
+		} else {
+			clean(c)
+		}
+		c = next
+	}
+}
+
+func unlikely(n *html.Node) bool {
+	if skipTags[n.DataAtom] {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	return negativeHint.MatchString(hints) && !positiveHint.MatchString(hints)
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score += 10
+	case atom.Div, atom.Section:
+		score += 5
+	case atom.Blockquote, atom.Pre, atom.Td:
+		score += 3
+	case atom.Ul, atom.Ol, atom.Dl, atom.Form, atom.Li:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.Th:
+		score -= 5
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	if positiveHint.MatchString(hints) {
+		score += 25
+	}
+	if negativeHint.MatchString(hints) {
+		score -= 25
+	}
+
+	return score
+}
+
+// linkDensity is the share of the text of n that is in links.
+func linkDensity(n *html.Node) float64 {
+	total := len(textOf(n))
+	if total == 0 {
+		return 0
+	}
+	var links int
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && d.DataAtom == atom.A {
+			links += len(textOf(d))
+		}
+	}
+
+	return float64(links) / float64(total)
+}
+
+func textOf(n *html.Node) string {
+	var sb strings.Builder
+	for d := range n.Descendants() {
+		if d.Type == html.TextNode {
+			sb.WriteString(d.Data)
+		}
+	}
+
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,128 @@
+package source
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	page, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("exp nil, got %v", err)
+	}
+
+	return page
+}
+
+func TestReadabilityExtract(t *testing.T) {
+	article := readFixture(t, "article.html")
+	links := readFixture(t, "links.html")
+	// the article only starts after the size limit, inside a long comment
+	large := append([]byte("<html><body><!--"+strings.Repeat("x", maxPageSize)+"-->"), article...)
+
+	mux := http.NewServeMux()
+	serve := func(path, contentType string, status int, page []byte) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.WriteHeader(status)
+			w.Write(page)
+		})
+	}
+	serve("/article", "text/html; charset=utf-8", http.StatusOK, article)
+	serve("/links", "text/html; charset=utf-8", http.StatusOK, links)
+	serve("/json", "application/json", http.StatusOK, article)
+	serve("/missing", "text/html; charset=utf-8", http.StatusNotFound, article)
+	serve("/error", "text/html; charset=utf-8", http.StatusInternalServerError, article)
+	serve("/large", "text/html; charset=utf-8", http.StatusOK, large)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	r := NewReadability(5 * time.Second)
+	for _, tc := range []struct {
+		name     string
+		path     string
+		expErr   error
+		expFail  bool
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "article",
+			path: "/article",
+			contains: []string{
+				"The river froze three weeks earlier than usual",
+				"the last time the river froze this early was in 1963",
+			},
+			excludes: []string{
+				"Navigation text",
+				"Sidebar text",
+				"Comment text",
+				"Footer text",
+				"window.analytics",
+			},
+		},
+		{
+			name:   "only links",
+			path:   "/links",
+			expErr: ErrNoContent,
+		},
+		{
+			name:    "not html",
+			path:    "/json",
+			expFail: true,
+		},
+		{
+			name:    "not found",
+			path:    "/missing",
+			expFail: true,
+		},
+		{
+			name:    "server error",
+			path:    "/error",
+			expFail: true,
+		},
+		{
+			name:   "beyond max page size",
+			path:   "/large",
+			expErr: ErrNoContent,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			act, err := r.Extract(domain.Entry{URL: srv.URL + tc.path})
+			switch {
+			case tc.expErr != nil:
+				if !errors.Is(err, tc.expErr) {
+					t.Fatalf("exp %v, got %v", tc.expErr, err)
+				}
+				return
+			case tc.expFail:
+				if err == nil {
+					t.Fatalf("exp error, got %q", act)
+				}
+				return
+			case err != nil:
+				t.Fatalf("exp nil, got %v", err)
+			}
+			for _, exp := range tc.contains {
+				if !strings.Contains(act, exp) {
+					t.Errorf("exp %q in\n%s", exp, act)
+				}
+			}
+			for _, exp := range tc.excludes {
+				if strings.Contains(act, exp) {
+					t.Errorf("exp no %q in\n%s", exp, act)
+				}
+			}
+		})
+	}
+}
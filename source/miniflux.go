@@ -0,0 +1,279 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	miniflux "miniflux.app/v2/client"
+)
+
+type Miniflux struct {
+	client *miniflux.Client
+}
+
+func NewMiniflux(host, apiKey string) *Miniflux {
+	return &Miniflux{
+		client: miniflux.NewClient(host, apiKey),
+	}
+}
+
+// Healthcheck checks that Miniflux can be reached and accepts the API key.
+func (mf *Miniflux) Healthcheck(ctx context.Context) error {
+	if _, err := mf.client.MeContext(ctx); err != nil {
+		return fmt.Errorf("miniflux not reachable: %v", err)
+	}
+
+	return nil
+}
+
+func (mf *Miniflux) Categories() ([]domain.Category, error) {
+	mfCats, err := mf.client.Categories()
+	if err != nil {
+		return nil, err
+	}
+	cats := make([]domain.Category, 0, len(mfCats))
+	for _, c := range mfCats {
+		cats = append(cats, domain.Category{
+			ID:    c.ID,
+			Title: c.Title,
+		})
+	}
+
+	return cats, nil
+}
+
+func (mf *Miniflux) Feeds() ([]domain.Feed, error) {
+	mfFeeds, err := mf.client.Feeds()
+	if err != nil {
+		return nil, err
+	}
+	feeds := make([]domain.Feed, 0, len(mfFeeds))
+	for _, f := range mfFeeds {
+		feeds = append(feeds, domain.Feed{
+			ID:         f.ID,
+			CategoryID: f.Category.ID,
+			SiteURL:    f.SiteURL,
+			FeedURL:    f.FeedURL,
+			Title:      f.Title,
+		})
+	}
+
+	return feeds, nil
+}
+
+func (mf *Miniflux) CreateCategory(title string) (domain.Category, error) {
+	c, err := mf.client.CreateCategory(title)
+	if err != nil {
+		return domain.Category{}, err
+	}
+
+	return domain.Category{ID: c.ID, Title: c.Title}, nil
+}
+
+// CreateFeed subscribes to the feed and returns the id of the new feed.
+func (mf *Miniflux) CreateFeed(feedURL string, categoryID int64) (int64, error) {
+	return mf.client.CreateFeed(&miniflux.FeedCreationRequest{
+		FeedURL:    feedURL,
+		CategoryID: categoryID,
+	})
+}
+
+type FeedStore interface {
+	AddCategories(cats []domain.Category) error
+	AddFeeds(feeds []domain.Feed) error
+}
+
+// SyncTo copies the categories and feeds from Miniflux to the store.
+func (mf *Miniflux) SyncTo(store FeedStore) error {
+	mfCats, err := mf.Categories()
+	if err != nil {
+		return fmt.Errorf("could not fetch miniflux categories: %v", err)
+	}
+	if err := store.AddCategories(mfCats); err != nil {
+		return fmt.Errorf("could not add postgres categories: %v", err)
+	}
+
+	mfFeeds, err := mf.Feeds()
+	if err != nil {
+		return fmt.Errorf("could not fetch miniflux feeds: %v", err)
+	}
+	if err := store.AddFeeds(mfFeeds); err != nil {
+		return fmt.Errorf("could not add postgres feeds: %v", err)
+	}
+
+	return nil
+}
+
+func (mf *Miniflux) Unread(categoryID int64) ([]domain.Entry, error) {
+	entries := make([]domain.Entry, 0)
+	result, err := mf.client.Entries(&miniflux.Filter{
+		Statuses:   []string{"unread"},
+		CategoryID: categoryID,
+		Order:      "published_at",
+		Direction:  "asc",
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range result.Entries {
+		if e.Feed == nil {
+			return nil, fmt.Errorf("could not fetch unread entries, entry without feed: %d", e.ID)
+		}
+		mdContent := ConvertHTMLToMarkdown(e.Content)
+		// if len(mdContent) > 500 {
+		// 	mdContent = mdContent[:500]
+		// }
+		mfe := domain.Entry{
+			ID:        e.ID,
+			FeedID:    e.Feed.ID,
+			Title:     e.Title,
+			URL:       e.URL,
+			Content:   mdContent,
+			Published: e.Date,
+		}
+		entries = append(entries, mfe)
+	}
+
+	return entries, nil
+}
+
+// Entry fetches a single entry, whatever its status, and the category of
+// its feed.
+func (mf *Miniflux) Entry(id int64) (domain.Entry, int64, error) {
+	e, err := mf.client.Entry(id)
+	if err != nil {
+		return domain.Entry{}, 0, err
+	}
+	if e.Feed == nil {
+		return domain.Entry{}, 0, fmt.Errorf("could not fetch entry, entry without feed: %d", e.ID)
+	}
+	var categoryID int64
+	if e.Feed.Category != nil {
+		categoryID = e.Feed.Category.ID
+	}
+
+	return domain.Entry{
+		ID:        e.ID,
+		FeedID:    e.Feed.ID,
+		Title:     e.Title,
+		URL:       e.URL,
+		Content:   ConvertHTMLToMarkdown(e.Content),
+		Published: e.Date,
+	}, categoryID, nil
+}
+
+// LastPublished returns the publication date of the newest entry of the
+// feed, read or not. It is zero when the feed has no entries.
+func (mf *Miniflux) LastPublished(feedID int64) (time.Time, error) {
+	result, err := mf.client.FeedEntries(feedID, &miniflux.Filter{
+		Order:     "published_at",
+		Direction: "desc",
+		Limit:     1,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(result.Entries) == 0 {
+		return time.Time{}, nil
+	}
+
+	return result.Entries[0].Date, nil
+}
+
+// UnreadCounts returns the number of unread entries per category.
+func (mf *Miniflux) UnreadCounts() (map[int64]int, error) {
+	mfCats, err := mf.client.CategoriesWithCounters()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int, len(mfCats))
+	for _, c := range mfCats {
+		if c.TotalUnread != nil {
+			counts[c.ID] = *c.TotalUnread
+		}
+	}
+
+	return counts, nil
+}
+
+type ReadEntry struct {
+	Entry   domain.Entry
+	Starred bool
+	Changed time.Time
+}
+
+// ReadEntries returns a page of read entries in the category, published
+// before the given time, together with the total number of such entries.
+func (mf *Miniflux) ReadEntries(categoryID int64, before time.Time, offset, limit int) ([]ReadEntry, int, error) {
+	result, err := mf.client.Entries(&miniflux.Filter{
+		Statuses:   []string{"read"},
+		CategoryID: categoryID,
+		Before:     before.Unix(),
+		Order:      "id",
+		Direction:  "asc",
+		Offset:     offset,
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := make([]ReadEntry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		if e.Feed == nil {
+			return nil, 0, fmt.Errorf("could not fetch read entries, entry without feed: %d", e.ID)
+		}
+		entries = append(entries, ReadEntry{
+			Entry: domain.Entry{
+				ID:        e.ID,
+				FeedID:    e.Feed.ID,
+				Title:     e.Title,
+				URL:       e.URL,
+				Content:   ConvertHTMLToMarkdown(e.Content),
+				Published: e.Date,
+			},
+			Starred: e.Starred,
+			Changed: e.ChangedAt,
+		})
+	}
+
+	return entries, result.Total, nil
+}
+
+func (mf *Miniflux) MarkRead(id ...int64) error {
+	if err := mf.client.UpdateEntries(id, "read"); err != nil {
+		return fmt.Errorf("could not mark entries read: %v", err)
+	}
+
+	return nil
+}
+
+func (mf *Miniflux) MarkUnread(id ...int64) error {
+	if err := mf.client.UpdateEntries(id, "unread"); err != nil {
+		return fmt.Errorf("could not mark entries unread: %v", err)
+	}
+
+	return nil
+}
+
+// Extract gets the original article of the entry with the scraper of
+// Miniflux.
+func (mf *Miniflux) Extract(entry domain.Entry) (string, error) {
+	content, err := mf.client.FetchEntryOriginalContent(entry.ID)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch original content: %v", err)
+	}
+
+	return ConvertHTMLToMarkdown(content), nil
+}
+
+func ConvertHTMLToMarkdown(html string) string {
+	markdown, err := htmltomarkdown.ConvertString(html)
+	if err != nil {
+		markdown = fmt.Sprintf("Error: could not convert html: %v", err)
+	}
+
+	return markdown
+}
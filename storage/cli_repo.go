@@ -2,11 +2,20 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
 )
 
+// CliRepo reports on the ratings. Entries with the weak_read label, imported
+// from the Miniflux history, are left out of the reports, they are only
+// used for training and the export.
 type CliRepo struct {
 	db *sql.DB
 }
@@ -17,7 +26,7 @@ func NewCliRepo(db *sql.DB) *CliRepo {
 
 func (r *CliRepo) TotalEntries() (int64, error) {
 	var count int64
-	if err := r.db.QueryRow("SELECT COUNT(*) FROM entry").Scan(&count); err != nil {
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM entry WHERE rating <> 'weak_read'").Scan(&count); err != nil {
 		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
 	}
 	return count, nil
@@ -28,6 +37,7 @@ func (r *CliRepo) EntriesByCategory() (map[int64]int64, error) {
 		SELECT feed.category_id, COUNT(*)
 		FROM entry
 		JOIN feed ON entry.feed_id = feed.id
+		WHERE entry.rating <> 'weak_read'
 		GROUP BY feed.category_id
 	`)
 	if err != nil {
@@ -49,7 +59,7 @@ func (r *CliRepo) EntriesByCategory() (map[int64]int64, error) {
 }
 
 func (r *CliRepo) RatingsByStatus() (map[string]int64, error) {
-	rows, err := r.db.Query("SELECT rating, COUNT(*) FROM entry GROUP BY rating")
+	rows, err := r.db.Query("SELECT rating, COUNT(*) FROM entry WHERE rating <> 'weak_read' GROUP BY rating")
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
 	}
@@ -73,6 +83,7 @@ func (r *CliRepo) CategoryRatingMatrix() (map[int64]map[string]int64, error) {
 		SELECT feed.category_id, entry.rating, COUNT(*)
 		FROM entry
 		JOIN feed ON entry.feed_id = feed.id
+		WHERE entry.rating <> 'weak_read'
 		GROUP BY feed.category_id, entry.rating
 	`)
 	if err != nil {
@@ -99,7 +110,7 @@ func (r *CliRepo) CategoryRatingMatrix() (map[int64]map[string]int64, error) {
 }
 
 func (r *CliRepo) AllRatings() ([]string, error) {
-	rows, err := r.db.Query("SELECT DISTINCT rating FROM entry ORDER BY rating")
+	rows, err := r.db.Query("SELECT DISTINCT rating FROM entry WHERE rating <> 'weak_read' ORDER BY rating")
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
 	}
@@ -117,6 +128,28 @@ func (r *CliRepo) AllRatings() ([]string, error) {
 	return ratings, nil
 }
 
+// RatingValues returns the values of the rating enum, in declaration order,
+// without weak_read.
+func (r *CliRepo) RatingValues() ([]string, error) {
+	values, err := ratingValues(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(values, func(v string) bool { return v == "weak_read" }), nil
+}
+
+// FirstRated returns the time of the earliest rating given in the TUI or the
+// web interface. It is zero when there are none.
+func (r *CliRepo) FirstRated() (time.Time, error) {
+	var first sql.NullTime
+	if err := r.db.QueryRow("SELECT MIN(updated) FROM entry WHERE rating <> 'weak_read'").Scan(&first); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return first.Time, nil
+}
+
 func (r *CliRepo) CategoryNames() (map[int64]string, error) {
 	rows, err := r.db.Query("SELECT id, title FROM category")
 	if err != nil {
@@ -136,3 +169,368 @@ func (r *CliRepo) CategoryNames() (map[int64]string, error) {
 
 	return result, nil
 }
+
+type FeedStat struct {
+	FeedID     int64
+	Title      string
+	CategoryID int64
+	Rated      int64
+	Finished   int64
+	NotOpened  int64
+	LastRated  time.Time
+}
+
+func (r *CliRepo) FeedStats() ([]FeedStat, error) {
+	rows, err := r.db.Query(`
+		SELECT feed.id, feed.title, feed.category_id, COUNT(*),
+			COUNT(*) FILTER (WHERE entry.rating = 'finished'),
+			COUNT(*) FILTER (WHERE entry.rating = 'not_opened'),
+			MAX(entry.updated)
+		FROM entry
+		JOIN feed ON entry.feed_id = feed.id
+		WHERE entry.rating <> 'weak_read'
+		GROUP BY feed.id, feed.title, feed.category_id
+		ORDER BY feed.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]FeedStat, 0)
+	for rows.Next() {
+		var fs FeedStat
+		if err := rows.Scan(&fs.FeedID, &fs.Title, &fs.CategoryID, &fs.Rated, &fs.Finished,
+			&fs.NotOpened, &fs.LastRated); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, fs)
+	}
+
+	return result, nil
+}
+
+type DayCount struct {
+	Day    time.Time
+	Rating string
+	Count  int64
+}
+
+// RatingsPerDay counts the ratings given since the given time per day and
+// rating. The days are split in the given zone, not in that of the Postgres
+// session, and Day is the midnight that starts it.
+func (r *CliRepo) RatingsPerDay(since time.Time, loc *time.Location) ([]DayCount, error) {
+	rows, err := r.db.Query(`
+		SELECT updated, rating
+		FROM entry
+		WHERE updated >= $1
+			AND rating <> 'weak_read'
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	type dayRating struct {
+		day    time.Time
+		rating string
+	}
+	counts := make(map[dayRating]int64)
+	for rows.Next() {
+		var updated time.Time
+		var rating string
+		if err := rows.Scan(&updated, &rating); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		local := updated.In(loc)
+		counts[dayRating{
+			day:    time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc),
+			rating: rating,
+		}]++
+	}
+
+	result := make([]DayCount, 0, len(counts))
+	for dr, count := range counts {
+		result = append(result, DayCount{Day: dr.day, Rating: dr.rating, Count: count})
+	}
+	slices.SortFunc(result, func(a, b DayCount) int {
+		if c := a.Day.Compare(b.Day); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Rating, b.Rating)
+	})
+
+	return result, nil
+}
+
+type LabelledEntry struct {
+	ID            int64
+	FeedID        int64
+	FeedTitle     string
+	CategoryID    int64
+	CategoryTitle string
+	Rating        string
+	Updated       time.Time
+	Title         string
+	URL           string
+	Content       string
+}
+
+// LabelledEntries returns the rated entries with their feed and category.
+// When before is set, only entries rated before that time are returned.
+func (r *CliRepo) LabelledEntries(before time.Time) ([]LabelledEntry, error) {
+	if before.IsZero() {
+		before = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	rows, err := r.db.Query(`
+		SELECT entry.id, feed.id, feed.title, category.id, category.title,
+			entry.rating, entry.updated, entry.title, entry.url, entry.content
+		FROM entry
+		JOIN feed ON entry.feed_id = feed.id
+		JOIN category ON feed.category_id = category.id
+		WHERE entry.updated < $1
+		ORDER BY entry.updated, entry.id
+	`, before)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]LabelledEntry, 0)
+	for rows.Next() {
+		var le LabelledEntry
+		if err := rows.Scan(&le.ID, &le.FeedID, &le.FeedTitle, &le.CategoryID, &le.CategoryTitle,
+			&le.Rating, &le.Updated, &le.Title, &le.URL, &le.Content); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, le)
+	}
+
+	return result, nil
+}
+
+type TrendCount struct {
+	Bucket  time.Time
+	GroupID int64
+	Rating  string
+	Count   int64
+}
+
+// RatingTrends counts the ratings per time bucket (day, week or month) and
+// per group (category or feed).
+func (r *CliRepo) RatingTrends(bucket, groupBy string, since time.Time) ([]TrendCount, error) {
+	switch bucket {
+	case "day", "week", "month":
+	default:
+		return nil, fmt.Errorf("unknown bucket %q", bucket)
+	}
+	var groupCol string
+	switch groupBy {
+	case "category":
+		groupCol = "feed.category_id"
+	case "feed":
+		groupCol = "feed.id"
+	default:
+		return nil, fmt.Errorf("unknown grouping %q", groupBy)
+	}
+
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT date_trunc($1, entry.updated), %s, entry.rating, COUNT(*)
+		FROM entry
+		JOIN feed ON entry.feed_id = feed.id
+		WHERE entry.updated >= $2 AND entry.rating <> 'weak_read'
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3
+	`, groupCol), bucket, since)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]TrendCount, 0)
+	for rows.Next() {
+		var tc TrendCount
+		if err := rows.Scan(&tc.Bucket, &tc.GroupID, &tc.Rating, &tc.Count); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, tc)
+	}
+
+	return result, nil
+}
+
+func (r *CliRepo) FeedNames() (map[int64]string, error) {
+	rows, err := r.db.Query("SELECT id, title FROM feed")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result[id] = title
+	}
+
+	return result, nil
+}
+
+// ImportEntries stores rated entries that are not in the database yet and
+// returns how many were added. Existing entries are left alone, so ratings
+// from the TUI always win over imported ones.
+func (r *CliRepo) ImportEntries(entries []domain.RatedEntry) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO entry
+(id, feed_id, updated, title, rating, url, content)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id) DO NOTHING`)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer stmt.Close()
+
+	var added int64
+	for _, re := range entries {
+		res, err := stmt.Exec(re.Entry.ID, re.Entry.FeedID, re.Updated, re.Entry.Title,
+			re.Rating, re.Entry.URL, re.Entry.Content)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		added += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return added, nil
+}
+
+// RatedEntriesByCategory returns the rated entries per category of their
+// feed.
+func (r *CliRepo) RatedEntriesByCategory() (map[int64][]domain.RatedEntry, error) {
+	rows, err := r.db.Query(`SELECT feed.category_id, entry.id, entry.feed_id, entry.title, entry.url,
+	entry.content, entry.rating, entry.updated
+FROM entry
+JOIN feed ON entry.feed_id = feed.id`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64][]domain.RatedEntry)
+	for rows.Next() {
+		var categoryID int64
+		var re domain.RatedEntry
+		if err := rows.Scan(&categoryID, &re.Entry.ID, &re.Entry.FeedID, &re.Entry.Title, &re.Entry.URL,
+			&re.Entry.Content, &re.Rating, &re.Updated); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result[categoryID] = append(result[categoryID], re)
+	}
+
+	return result, nil
+}
+
+func (r *CliRepo) Rules() (scoring.Rules, error) {
+	return storedRules(r.db)
+}
+
+// ReplaceRuleCandidates replaces the candidates of the previous mining run
+// with new ones.
+func (r *CliRepo) ReplaceRuleCandidates(candidates []scoring.RuleCandidate, created time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM rule_candidate`); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO rule_candidate
+(category_id, host, term, action, weight, support, confidence, created)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer stmt.Close()
+
+	for _, c := range candidates {
+		if _, err := stmt.Exec(c.CategoryID, c.Host, c.Term, c.Action, c.Weight, c.Support, c.Confidence, created.UTC()); err != nil {
+			return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+func (r *CliRepo) RuleCandidates() ([]scoring.RuleCandidate, error) {
+	rows, err := r.db.Query(`SELECT id, category_id, host, term, action, weight, support, confidence
+FROM rule_candidate
+ORDER BY category_id, confidence DESC, support DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]scoring.RuleCandidate, 0)
+	for rows.Next() {
+		var c scoring.RuleCandidate
+		if err := rows.Scan(&c.ID, &c.CategoryID, &c.Host, &c.Term, &c.Action, &c.Weight, &c.Support, &c.Confidence); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+// AcceptRuleCandidate moves a candidate to the rule set and returns the new
+// rule.
+func (r *CliRepo) AcceptRuleCandidate(id int64, created time.Time) (scoring.Rule, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return scoring.Rule{}, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	var c scoring.RuleCandidate
+	err = tx.QueryRow(`DELETE FROM rule_candidate
+WHERE id = $1
+RETURNING category_id, host, term, action, weight`, id).Scan(&c.CategoryID, &c.Host, &c.Term, &c.Action, &c.Weight)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return scoring.Rule{}, fmt.Errorf("%w: rule candidate %d", ErrNotFound, id)
+	case err != nil:
+		return scoring.Rule{}, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	rule := c.Rule()
+	if _, err := tx.Exec(`INSERT INTO rule
+(name, category_id, host, term, action, weight, created)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		rule.Name, rule.CategoryID, rule.Host, rule.Term, rule.Action, rule.Weight, created.UTC()); err != nil {
+		return scoring.Rule{}, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return scoring.Rule{}, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return rule, nil
+}
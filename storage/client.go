@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
 )
 
 var (
 	ErrInvalidConfiguration     = errors.New("invalid configuration")
 	ErrPostgresFailure          = errors.New("postgres returned an error")
+	ErrNotFound                 = errors.New("not found")
 	ErrNotEnoughSQLMigrations   = errors.New("already more migrations than wanted")
 	ErrIncompatibleSQLMigration = errors.New("incompatible migration")
 )
@@ -56,6 +59,15 @@ func (c *Client) DB() *sql.DB {
 	return c.db
 }
 
+// Migrations returns the number of migrations applied to the database.
+func (c *Client) Migrations() (int, error) {
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM migration`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	return count, nil
+}
+
 func (c *Client) migrate() error {
 	// Create migration table if not exists
 	_, err := c.db.Exec(`
@@ -123,3 +135,152 @@ func compareMigrations(wanted, existing []string) ([]string, error) {
 
 	return needed, nil
 }
+
+func ratingValues(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT unnest(enum_range(NULL::rating))::text`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, value)
+	}
+
+	return result, nil
+}
+
+func ratedEntries(db *sql.DB) ([]domain.RatedEntry, error) {
+	rows, err := db.Query(`SELECT id, feed_id, title, url, content, rating, updated FROM entry`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]domain.RatedEntry, 0)
+	for rows.Next() {
+		var re domain.RatedEntry
+		if err := rows.Scan(&re.Entry.ID, &re.Entry.FeedID, &re.Entry.Title, &re.Entry.URL,
+			&re.Entry.Content, &re.Rating, &re.Updated); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+// currentEdition returns the latest edition of a category, or nil when the
+// category has none.
+func currentEdition(db *sql.DB, categoryID int64) (*domain.Edition, error) {
+	ed := &domain.Edition{CategoryID: categoryID, EntryIDs: make([]int64, 0)}
+	err := db.QueryRow(`SELECT id, created, valid_until
+FROM edition
+WHERE category_id = $1
+ORDER BY created DESC, id DESC
+LIMIT 1`, categoryID).Scan(&ed.ID, &ed.Created, &ed.ValidUntil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	rows, err := db.Query(`SELECT entry_id FROM edition_entry WHERE edition_id = $1`, ed.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		ed.EntryIDs = append(ed.EntryIDs, id)
+	}
+
+	return ed, nil
+}
+
+func scanSnoozed(rows *sql.Rows) ([]domain.SnoozedEntry, error) {
+	defer rows.Close()
+
+	result := make([]domain.SnoozedEntry, 0)
+	for rows.Next() {
+		var se domain.SnoozedEntry
+		if err := rows.Scan(&se.Entry.ID, &se.CategoryID, &se.Entry.FeedID, &se.Entry.Title,
+			&se.Entry.URL, &se.Entry.Content, &se.Entry.Published, &se.Score, &se.Snoozed); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, se)
+	}
+
+	return result, nil
+}
+
+// storedRules returns the rules that were accepted from the mined candidates.
+func storedRules(db *sql.DB) (scoring.Rules, error) {
+	rows, err := db.Query(`SELECT name, category_id, host, term, action, weight FROM rule ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make(scoring.Rules, 0)
+	for rows.Next() {
+		var r scoring.Rule
+		if err := rows.Scan(&r.Name, &r.CategoryID, &r.Host, &r.Term, &r.Action, &r.Weight); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+// extracted returns the article content that was extracted for the entries.
+// An empty content means the extraction was tried, but failed.
+func extracted(db *sql.DB, ids []int64) (map[int64]string, error) {
+	rows, err := db.Query(`SELECT entry_id, content FROM extracted WHERE entry_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result[id] = content
+	}
+
+	return result, nil
+}
+
+// withExtracted replaces the feed content of the entries by the extracted
+// article, where there is one.
+func withExtracted(db *sql.DB, entries []domain.Entry) ([]domain.Entry, error) {
+	ids := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	content, err := extracted(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		if c := content[e.ID]; c != "" {
+			entries[i].Content = c
+		}
+	}
+
+	return entries, nil
+}
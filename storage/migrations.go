@@ -25,4 +25,77 @@ var migrations = []string{
   	content TEXT
 	)`,
 	`ALTER TYPE rating ADD VALUE 'only_comments'`,
+	`ALTER TABLE entry ADD COLUMN search tsvector
+	GENERATED ALWAYS AS (
+  	to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(content, ''))
+	) STORED`,
+	`CREATE INDEX entry_search_idx ON entry USING GIN (search)`,
+	`ALTER TYPE rating ADD VALUE 'weak_read'`,
+	`CREATE TABLE duplicate (
+  	entry_id INTEGER PRIMARY KEY,
+  	kept_id INTEGER,
+  	category_id INTEGER,
+  	distance INTEGER,
+  	title TEXT,
+  	url TEXT,
+  	created TIMESTAMP
+	)`,
+	`CREATE TABLE schedule (
+  	category_id INTEGER PRIMARY KEY,
+  	last_run TIMESTAMP,
+  	next_run TIMESTAMP
+	)`,
+	`CREATE TABLE edition (
+  	id SERIAL PRIMARY KEY,
+  	category_id INTEGER,
+  	created TIMESTAMP,
+  	valid_until TIMESTAMP
+	)`,
+	`CREATE TABLE edition_entry (
+  	edition_id INTEGER REFERENCES edition(id),
+  	entry_id INTEGER,
+  	PRIMARY KEY (edition_id, entry_id)
+	)`,
+	`CREATE TABLE snoozed (
+  	entry_id INTEGER PRIMARY KEY,
+  	category_id INTEGER,
+  	feed_id INTEGER,
+  	title TEXT,
+  	url TEXT,
+  	content TEXT,
+  	published TIMESTAMP,
+  	score REAL,
+  	snoozed TIMESTAMP,
+  	resurfaced TIMESTAMP
+	)`,
+	`CREATE TABLE rule_candidate (
+  	id SERIAL PRIMARY KEY,
+  	host TEXT,
+  	term TEXT,
+  	action TEXT,
+  	weight REAL,
+  	support INTEGER,
+  	confidence REAL,
+  	created TIMESTAMP
+	)`,
+	`CREATE TABLE rule (
+  	id SERIAL PRIMARY KEY,
+  	name TEXT,
+  	category_id INTEGER,
+  	host TEXT,
+  	term TEXT,
+  	action TEXT,
+  	weight REAL,
+  	created TIMESTAMP
+	)`,
+	`CREATE TABLE extracted (
+  	entry_id INTEGER PRIMARY KEY,
+  	content TEXT,
+  	created TIMESTAMP
+	)`,
+	`ALTER TABLE edition_entry ADD COLUMN feed_id INTEGER`,
+	`UPDATE edition_entry SET feed_id = entry.feed_id
+	FROM entry
+	WHERE entry.id = edition_entry.entry_id`,
+	`ALTER TABLE rule_candidate ADD COLUMN category_id INTEGER NOT NULL DEFAULT 0`,
 }
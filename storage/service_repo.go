@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+)
+
+type ServiceRepo struct {
+	db *sql.DB
+}
+
+func NewServiceRepo(db *sql.DB) *ServiceRepo {
+	return &ServiceRepo{db: db}
+}
+
+func (r *ServiceRepo) RatedEntries() ([]domain.RatedEntry, error) {
+	return ratedEntries(r.db)
+}
+
+func (r *ServiceRepo) CurrentEdition(categoryID int64) (*domain.Edition, error) {
+	return currentEdition(r.db, categoryID)
+}
+
+// KeptSince counts per feed the entries that were in an edition of the
+// category made since the given time, read or not. An entry that was in
+// more than one edition counts once.
+func (r *ServiceRepo) KeptSince(categoryID int64, since time.Time) (map[int64]int, error) {
+	rows, err := r.db.Query(`SELECT edition_entry.feed_id, COUNT(DISTINCT edition_entry.entry_id)
+FROM edition_entry
+JOIN edition ON edition.id = edition_entry.edition_id
+WHERE edition.category_id = $1 AND edition.created >= $2 AND edition_entry.feed_id IS NOT NULL
+GROUP BY edition_entry.feed_id`, categoryID, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]int)
+	for rows.Next() {
+		var feedID int64
+		var count int
+		if err := rows.Scan(&feedID, &count); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result[feedID] = count
+	}
+
+	return result, nil
+}
+
+// AddEdition stores the kept entries of a run and returns the id of the new
+// edition. The feed of every entry is stored with it, for the daily feed cap.
+func (r *ServiceRepo) AddEdition(ed domain.Edition, feedIDs map[int64]int64) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow(`INSERT INTO edition
+(category_id, created, valid_until)
+VALUES ($1, $2, $3)
+RETURNING id`,
+		ed.CategoryID, ed.Created.UTC(), ed.ValidUntil.UTC(),
+	).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	for _, entryID := range ed.EntryIDs {
+		if _, err := tx.Exec(`INSERT INTO edition_entry
+(edition_id, entry_id, feed_id)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING`, id, entryID, feedIDs[entryID]); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return id, nil
+}
+
+// Snooze puts dropped entries aside. An entry that is snoozed again after it
+// resurfaced gets the new snooze time, so it can not come back in the run
+// that dropped it.
+func (r *ServiceRepo) Snooze(entries []domain.SnoozedEntry) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO snoozed
+(entry_id, category_id, feed_id, title, url, content, published, score, snoozed)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (entry_id)
+DO UPDATE SET
+score = EXCLUDED.score,
+snoozed = EXCLUDED.snoozed,
+resurfaced = NULL`)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer stmt.Close()
+
+	for _, se := range entries {
+		if _, err := stmt.Exec(se.Entry.ID, se.CategoryID, se.Entry.FeedID, se.Entry.Title,
+			se.Entry.URL, se.Entry.Content, se.Entry.Published.UTC(), se.Score, se.Snoozed.UTC()); err != nil {
+			return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+// Snoozed returns the best scored entries of a category that were snoozed
+// between from and to and have at least minScore, leaving out the excluded
+// entries.
+func (r *ServiceRepo) Snoozed(categoryID int64, from, to time.Time, minScore float64, limit int, exclude []int64) ([]domain.SnoozedEntry, error) {
+	rows, err := r.db.Query(`SELECT entry_id, category_id, feed_id, title, url, content, published, score, snoozed
+FROM snoozed
+WHERE category_id = $1 AND snoozed >= $2 AND snoozed < $3 AND score >= $4 AND resurfaced IS NULL
+	AND NOT entry_id = ANY($6)
+ORDER BY score DESC
+LIMIT $5`, categoryID, from.UTC(), to.UTC(), minScore, limit, pq.Array(exclude))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return scanSnoozed(rows)
+}
+
+func (r *ServiceRepo) Resurface(ids []int64, now time.Time) error {
+	if _, err := r.db.Exec(`UPDATE snoozed SET resurfaced = $1 WHERE entry_id = ANY($2)`,
+		now.UTC(), pq.Array(ids)); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+// PruneEditions removes the editions of the category that were made before
+// the given time, except the one that is kept.
+func (r *ServiceRepo) PruneEditions(categoryID, keepID int64, before time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM edition_entry
+WHERE edition_id IN (
+	SELECT id FROM edition WHERE category_id = $1 AND id <> $2 AND created < $3
+)`, categoryID, keepID, before.UTC()); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM edition
+WHERE category_id = $1 AND id <> $2 AND created < $3`, categoryID, keepID, before.UTC()); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+// AddDuplicates records the entries that were marked read because they told
+// the same story as an entry that was kept.
+func (r *ServiceRepo) AddDuplicates(categoryID int64, dups []scoring.Duplicate, created time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO duplicate
+(entry_id, kept_id, category_id, distance, title, url, created)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (entry_id) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer stmt.Close()
+
+	for _, d := range dups {
+		if _, err := stmt.Exec(d.Entry.ID, d.KeptID, categoryID, d.Distance,
+			d.Entry.Title, d.Entry.URL, created); err != nil {
+			return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+type ScheduleRun struct {
+	LastRun time.Time
+	NextRun time.Time
+}
+
+// ScheduleRuns returns the last and next run of every category that has been
+// scheduled before.
+func (r *ServiceRepo) ScheduleRuns() (map[int64]ScheduleRun, error) {
+	rows, err := r.db.Query(`SELECT category_id, last_run, next_run FROM schedule`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]ScheduleRun)
+	for rows.Next() {
+		var catID int64
+		var lastRun sql.NullTime
+		var run ScheduleRun
+		if err := rows.Scan(&catID, &lastRun, &run.NextRun); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		run.LastRun = lastRun.Time
+		result[catID] = run
+	}
+
+	return result, nil
+}
+
+func (r *ServiceRepo) StoreScheduleRun(categoryID int64, run ScheduleRun) error {
+	var lastRun sql.NullTime
+	if !run.LastRun.IsZero() {
+		lastRun = sql.NullTime{Time: run.LastRun.UTC(), Valid: true}
+	}
+	if _, err := r.db.Exec(`INSERT INTO schedule
+(category_id, last_run, next_run)
+VALUES ($1, $2, $3)
+ON CONFLICT (category_id)
+DO UPDATE SET
+last_run = EXCLUDED.last_run,
+next_run = EXCLUDED.next_run`,
+		categoryID, lastRun, run.NextRun.UTC(),
+	); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+func (r *ServiceRepo) Rules() (scoring.Rules, error) {
+	return storedRules(r.db)
+}
+
+func (r *ServiceRepo) Extracted(ids []int64) (map[int64]string, error) {
+	return extracted(r.db, ids)
+}
+
+func (r *ServiceRepo) WithExtracted(entries []domain.Entry) ([]domain.Entry, error) {
+	return withExtracted(r.db, entries)
+}
+
+// StoreExtracted stores the article content extracted for an entry. Failed
+// extractions are stored with empty content, so they are not tried again.
+func (r *ServiceRepo) StoreExtracted(entryID int64, content string, created time.Time) error {
+	if _, err := r.db.Exec(`INSERT INTO extracted (entry_id, content, created)
+VALUES ($1, $2, $3)
+ON CONFLICT (entry_id) DO UPDATE SET content = EXCLUDED.content, created = EXCLUDED.created`,
+		entryID, content, created.UTC()); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
 )
 
 type TuiRepo struct {
@@ -95,11 +97,19 @@ title = EXCLUDED.title`, strings.Join(values, ","))
 	return nil
 }
 
-func (r *TuiRepo) StoreEntry(entry domain.Entry, rating string) error {
+// StoreEntry saves the rating for an entry. A rating only replaces one that
+// was given earlier, so a retried or replayed store does not overwrite a
+// newer rating.
+func (r *TuiRepo) StoreEntry(entry domain.Entry, rating string, updated time.Time) error {
 	if _, err := r.db.Exec(`INSERT INTO entry
 (id, feed_id, updated, title, rating, url, content)
-VALUES ($1, $2, NOW(), $3, $4, $5, $6)`,
-		entry.ID, entry.FeedID, entry.Title,
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id)
+DO UPDATE SET
+updated = EXCLUDED.updated,
+rating = EXCLUDED.rating
+WHERE entry.updated IS NULL OR entry.updated <= EXCLUDED.updated`,
+		entry.ID, entry.FeedID, updated, entry.Title,
 		rating, entry.URL, entry.Content,
 	); err != nil {
 		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
@@ -107,3 +117,112 @@ VALUES ($1, $2, NOW(), $3, $4, $5, $6)`,
 
 	return nil
 }
+
+func (r *TuiRepo) RatedEntries() ([]domain.RatedEntry, error) {
+	return ratedEntries(r.db)
+}
+
+func (r *TuiRepo) CurrentEdition(categoryID int64) (*domain.Edition, error) {
+	return currentEdition(r.db, categoryID)
+}
+
+// Snoozed returns the entries that are waiting to resurface, best score
+// first.
+func (r *TuiRepo) Snoozed() ([]domain.SnoozedEntry, error) {
+	rows, err := r.db.Query(`SELECT entry_id, category_id, feed_id, title, url, content, published, score, snoozed
+FROM snoozed
+WHERE resurfaced IS NULL
+ORDER BY score DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return scanSnoozed(rows)
+}
+
+// Unsnooze removes an entry from the snoozed entries, after it was rated.
+func (r *TuiRepo) Unsnooze(id int64) error {
+	if _, err := r.db.Exec(`DELETE FROM snoozed WHERE entry_id = $1`, id); err != nil {
+		return fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+
+	return nil
+}
+
+type SearchQuery struct {
+	Text    string
+	Rating  string
+	FeedIDs []int64
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+func (r *TuiRepo) Search(q SearchQuery) ([]domain.RatedEntry, error) {
+	where := make([]string, 0)
+	args := make([]any, 0)
+	order := "updated DESC"
+	if q.Text != "" {
+		args = append(args, q.Text)
+		where = append(where, fmt.Sprintf("search @@ websearch_to_tsquery('simple', $%d)", len(args)))
+		order = fmt.Sprintf("ts_rank(search, websearch_to_tsquery('simple', $%d)) DESC, updated DESC", len(args))
+	}
+	if q.Rating != "" {
+		args = append(args, q.Rating)
+		where = append(where, fmt.Sprintf("rating = $%d", len(args)))
+	}
+	if len(q.FeedIDs) > 0 {
+		args = append(args, pq.Array(q.FeedIDs))
+		where = append(where, fmt.Sprintf("feed_id = ANY($%d)", len(args)))
+	}
+	if !q.From.IsZero() {
+		args = append(args, q.From)
+		where = append(where, fmt.Sprintf("updated >= $%d", len(args)))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To)
+		where = append(where, fmt.Sprintf("updated < $%d", len(args)))
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit)
+
+	query := `SELECT id, feed_id, title, url, content, rating, updated FROM entry`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d", order, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+	}
+	defer rows.Close()
+
+	result := make([]domain.RatedEntry, 0)
+	for rows.Next() {
+		var re domain.RatedEntry
+		if err := rows.Scan(&re.Entry.ID, &re.Entry.FeedID, &re.Entry.Title, &re.Entry.URL,
+			&re.Entry.Content, &re.Rating, &re.Updated); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPostgresFailure, err)
+		}
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+// RatingValues returns the values of the rating enum, in declaration order.
+func (r *TuiRepo) RatingValues() ([]string, error) {
+	return ratingValues(r.db)
+}
+
+func (r *TuiRepo) Rules() (scoring.Rules, error) {
+	return storedRules(r.db)
+}
+
+func (r *TuiRepo) WithExtracted(entries []domain.Entry) ([]domain.Entry, error) {
+	return withExtracted(r.db, entries)
+}
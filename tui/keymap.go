@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	actionQuit     = "quit"
+	actionRefresh  = "refresh"
+	actionSort     = "sort"
+	actionSearch   = "search"
+	actionStats    = "stats"
+	actionSnoozed  = "snoozed"
+	actionCategory = "category"
+	actionUp       = "up"
+	actionDown     = "down"
+	actionBack     = "back"
+)
+
+type Binding struct {
+	Action string
+	Keys   []string
+	Help   string
+}
+
+type RatingBinding struct {
+	Key   string
+	Value string
+	Label string
+}
+
+// KeyMap holds the active key bindings. Every binding can be overridden in
+// the config file with a key_<action> entry holding a comma separated list of
+// keys, e.g. key_down = "down,j". The ratings are configured with a single
+// ratings entry of key:value:label triples, e.g.
+// ratings = "1:not_opened:Not opened,2:finished:Finished".
+type KeyMap struct {
+	Bindings []Binding
+	Ratings  []RatingBinding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Bindings: []Binding{
+			{Action: actionUp, Keys: []string{"up"}, Help: "up"},
+			{Action: actionDown, Keys: []string{"down"}, Help: "down"},
+			{Action: actionCategory, Keys: []string{"left", "right"}, Help: "change category"},
+			{Action: actionRefresh, Keys: []string{"r"}, Help: "refresh"},
+			{Action: actionSort, Keys: []string{"s"}, Help: "toggle sort by score"},
+			{Action: actionSearch, Keys: []string{"/"}, Help: "search"},
+			{Action: actionStats, Keys: []string{"d"}, Help: "statistics"},
+			{Action: actionSnoozed, Keys: []string{"z"}, Help: "snoozed entries"},
+			{Action: actionBack, Keys: []string{"esc"}, Help: "back"},
+			{Action: actionQuit, Keys: []string{"q", "ctrl+c"}, Help: "quit"},
+		},
+		Ratings: []RatingBinding{
+			{Key: "1", Value: "not_opened", Label: "Not opened"},
+			{Key: "2", Value: "only_comments", Label: "Only comments"},
+			{Key: "3", Value: "not_finished", Label: "Not finished"},
+			{Key: "4", Value: "finished", Label: "Finished"},
+		},
+	}
+}
+
+// LoadKeyMap applies the overrides from the config to the default key map
+// and checks that no key is bound twice.
+func LoadKeyMap(conf map[string]string) (KeyMap, error) {
+	km := DefaultKeyMap()
+	for i, b := range km.Bindings {
+		if keys, ok := conf["key_"+b.Action]; ok {
+			km.Bindings[i].Keys = splitList(keys)
+		}
+	}
+	if ratings, ok := conf["ratings"]; ok {
+		km.Ratings = make([]RatingBinding, 0)
+		for _, r := range splitList(ratings) {
+			parts := strings.SplitN(r, ":", 3)
+			if len(parts) != 3 {
+				return KeyMap{}, fmt.Errorf("invalid rating binding %q, use key:value:label", r)
+			}
+			km.Ratings = append(km.Ratings, RatingBinding{
+				Key:   strings.TrimSpace(parts[0]),
+				Value: strings.TrimSpace(parts[1]),
+				Label: strings.TrimSpace(parts[2]),
+			})
+		}
+	}
+
+	seen := make(map[string]string)
+	check := func(key, name string) error {
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("key %q is bound to both %s and %s", key, other, name)
+		}
+		seen[key] = name
+		return nil
+	}
+	for _, b := range km.Bindings {
+		for _, k := range b.Keys {
+			if err := check(k, b.Action); err != nil {
+				return KeyMap{}, err
+			}
+		}
+	}
+	for _, r := range km.Ratings {
+		if err := check(r.Key, "rating "+r.Value); err != nil {
+			return KeyMap{}, err
+		}
+	}
+
+	return km, nil
+}
+
+// Validate checks that all configured ratings are known to the database.
+func (km KeyMap) Validate(known []string) error {
+	for _, r := range km.Ratings {
+		if !slices.Contains(known, r.Value) {
+			return fmt.Errorf("unknown rating %q, choose from %s", r.Value, strings.Join(known, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (km KeyMap) Action(key string) string {
+	for _, b := range km.Bindings {
+		if slices.Contains(b.Keys, key) {
+			return b.Action
+		}
+	}
+
+	return ""
+}
+
+func (km KeyMap) Rating(key string) (RatingBinding, bool) {
+	for _, r := range km.Ratings {
+		if r.Key == key {
+			return r, true
+		}
+	}
+
+	return RatingBinding{}, false
+}
+
+// Help describes the bindings for the given actions, in key map order.
+func (km KeyMap) Help(actions ...string) string {
+	parts := make([]string, 0, len(actions))
+	for _, b := range km.Bindings {
+		if !slices.Contains(actions, b.Action) || len(b.Keys) == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", strings.Join(b.Keys, "/"), b.Help))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (km KeyMap) RatingHelp() string {
+	parts := make([]string, 0, len(km.Ratings))
+	for _, r := range km.Ratings {
+		parts = append(parts, fmt.Sprintf("%s: %s", r.Key, r.Label))
+	}
+
+	return "Rate: " + strings.Join(parts, ", ")
+}
+
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
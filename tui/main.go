@@ -4,9 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
 	tea "github.com/charmbracelet/bubbletea"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
 	"go-mod.ewintr.nl/algorithmic-rss/storage"
 )
 
@@ -23,7 +25,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	mf := NewMiniflux(conf["miniflux_hostname"], conf["miniflux_api_key"])
+	mf := source.NewMiniflux(conf["miniflux_hostname"], conf["miniflux_api_key"])
 
 	pqCfg := &storage.Config{
 		PGHostname: conf["postgres_hostname"],
@@ -40,12 +42,39 @@ func main() {
 	defer pqClient.Close()
 
 	tuiRepo := storage.NewTuiRepo(pqClient.DB())
-	if err := updatePGCategoriesAndFeeds(mf, tuiRepo); err != nil {
+	if err := mf.SyncTo(tuiRepo); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	p := tea.NewProgram(InitialModel(mf, tuiRepo), tea.WithAltScreen())
+	keys, err := LoadKeyMap(conf)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	ratings, err := tuiRepo.RatingValues()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := keys.Validate(ratings); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	outboxPath, ok := conf["outbox_path"]
+	if !ok {
+		outboxPath = filepath.Join(filepath.Dir(*configPath), "outbox.jsonl")
+	}
+	outbox, err := OpenOutbox(outboxPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	sortByScore := conf["sort_by_score"] == "true"
+	cliRepo := storage.NewCliRepo(pqClient.DB())
+	p := tea.NewProgram(InitialModel(mf, tuiRepo, cliRepo, outbox, keys, sortByScore), tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -64,23 +93,3 @@ func loadConf(path string) (map[string]string, error) {
 
 	return config, nil
 }
-
-func updatePGCategoriesAndFeeds(mf *Miniflux, repo *storage.TuiRepo) error {
-	mfCats, err := mf.Categories()
-	if err != nil {
-		return fmt.Errorf("could not fetch miniflux categories: %v", err)
-	}
-	if err := repo.AddCategories(mfCats); err != nil {
-		return fmt.Errorf("could not add postgres categories: %v", err)
-	}
-
-	mfFeeds, err := mf.Feeds()
-	if err != nil {
-		return fmt.Errorf("could not fetch miniflux categories: %v", err)
-	}
-	if err := repo.AddFeeds(mfFeeds); err != nil {
-		return fmt.Errorf("could not add postgres feeds: %v", err)
-	}
-
-	return nil
-}
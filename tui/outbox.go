@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+const (
+	opStore    = "store"
+	opMarkRead = "mark_read"
+	opUnsnooze = "unsnooze"
+
+	outboxMinBackoff  = 5 * time.Second
+	outboxMaxBackoff  = 10 * time.Minute
+	outboxMaxAttempts = 10
+)
+
+type Op struct {
+	ID       string        `json:"id"`
+	Kind     string        `json:"kind"`
+	EntryID  int64         `json:"entry_id"`
+	Entry    *domain.Entry `json:"entry,omitempty"`
+	Rating   string        `json:"rating,omitempty"`
+	Created  time.Time     `json:"created"`
+	attempts int
+	nextTry  time.Time
+}
+
+type outboxRecord struct {
+	Op   *Op    `json:"op,omitempty"`
+	Done string `json:"done,omitempty"`
+}
+
+// Outbox is a durable queue of operations on Postgres and Miniflux. Every
+// operation is written to a JSON lines file before it is attempted and is
+// only removed after it succeeded, so nothing gets lost when one of the
+// stores is unreachable or the TUI is closed in between. The operations are
+// idempotent, which makes a retry after a crash between applying and
+// recording safe.
+//
+// Operations that keep failing are moved to a second file, next to the
+// outbox, where they stay until the user deals with them.
+type Outbox struct {
+	mu         sync.Mutex
+	running    sync.Mutex
+	path       string
+	failedPath string
+	pending    []*Op
+	failed     int
+}
+
+func OpenOutbox(path string) (*Outbox, error) {
+	ext := filepath.Ext(path)
+	o := &Outbox{
+		path:       path,
+		failedPath: strings.TrimSuffix(path, ext) + ".failed" + ext,
+		pending:    make([]*Op, 0),
+	}
+	failed, err := countLines(o.failedPath)
+	if err != nil {
+		return nil, err
+	}
+	o.failed = failed
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return o, nil
+	case err != nil:
+		return nil, fmt.Errorf("could not open outbox: %v", err)
+	}
+	defer f.Close()
+
+	done := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec outboxRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// a partially written last line after a crash
+			continue
+		}
+		switch {
+		case rec.Op != nil:
+			o.pending = append(o.pending, rec.Op)
+		case rec.Done != "":
+			done[rec.Done] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read outbox: %v", err)
+	}
+
+	pending := make([]*Op, 0, len(o.pending))
+	for _, op := range o.pending {
+		if !done[op.ID] {
+			pending = append(pending, op)
+		}
+	}
+	o.pending = pending
+
+	if err := o.compact(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// Add stores the operations durably. They are not attempted until the next
+// call to Process.
+func (o *Outbox) Add(ops ...*Op) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	recs := make([]outboxRecord, 0, len(ops))
+	for _, op := range ops {
+		recs = append(recs, outboxRecord{Op: op})
+	}
+	if err := o.append(recs...); err != nil {
+		return err
+	}
+	o.pending = append(o.pending, ops...)
+
+	return nil
+}
+
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.pending)
+}
+
+// Failed returns the number of operations in the failed file.
+func (o *Outbox) Failed() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.failed
+}
+
+func (o *Outbox) FailedPath() string {
+	return o.failedPath
+}
+
+// Process applies all operations that are due, in the order they were added.
+// An operation on an entry waits until the earlier operations on the same
+// entry succeeded, so an entry is never marked read before its rating is
+// stored. Failed operations are retried later with exponential backoff. After
+// outboxMaxAttempts the operation and the later ones on the same entry are
+// moved to the failed file. Only one Process runs at a time, a concurrent
+// call returns immediately.
+func (o *Outbox) Process(now time.Time, apply func(*Op) error) error {
+	if !o.running.TryLock() {
+		return nil
+	}
+	defer o.running.Unlock()
+
+	o.mu.Lock()
+	ops := slices.Clone(o.pending)
+	o.mu.Unlock()
+
+	blocked := make(map[int64]bool)
+	var lastErr error
+	for i, op := range ops {
+		if blocked[op.EntryID] {
+			continue
+		}
+		if op.nextTry.After(now) {
+			blocked[op.EntryID] = true
+			continue
+		}
+		if err := apply(op); err != nil {
+			blocked[op.EntryID] = true
+			lastErr = err
+			op.attempts++
+			if op.attempts < outboxMaxAttempts {
+				backoff := min(outboxMinBackoff<<min(op.attempts-1, 16), outboxMaxBackoff)
+				op.nextTry = now.Add(backoff)
+				continue
+			}
+			failed := []*Op{op}
+			for _, later := range ops[i+1:] {
+				if later.EntryID == op.EntryID {
+					failed = append(failed, later)
+				}
+			}
+			if err := o.fail(failed); err != nil {
+				return err
+			}
+			lastErr = fmt.Errorf("gave up on %s of entry %d after %d attempts: %v", op.Kind, op.EntryID, op.attempts, err)
+			continue
+		}
+		if err := o.done(op.ID); err != nil {
+			return err
+		}
+	}
+
+	return lastErr
+}
+
+func (o *Outbox) done(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.append(outboxRecord{Done: id}); err != nil {
+		return err
+	}
+
+	return o.remove(id)
+}
+
+// fail moves the operations to the failed file.
+func (o *Outbox) fail(ops []*Op) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lines := make([]any, 0, len(ops))
+	recs := make([]outboxRecord, 0, len(ops))
+	ids := make([]string, 0, len(ops))
+	for _, op := range ops {
+		lines = append(lines, op)
+		recs = append(recs, outboxRecord{Done: op.ID})
+		ids = append(ids, op.ID)
+	}
+	if err := appendJSON(o.failedPath, lines...); err != nil {
+		return err
+	}
+	o.failed += len(ops)
+	if err := o.append(recs...); err != nil {
+		return err
+	}
+
+	return o.remove(ids...)
+}
+
+// remove takes the operations out of the pending ones. It must be called
+// with the lock held.
+func (o *Outbox) remove(ids ...string) error {
+	o.pending = slices.DeleteFunc(o.pending, func(op *Op) bool {
+		return slices.Contains(ids, op.ID)
+	})
+	if len(o.pending) == 0 {
+		return o.compact()
+	}
+
+	return nil
+}
+
+func (o *Outbox) append(recs ...outboxRecord) error {
+	values := make([]any, 0, len(recs))
+	for _, rec := range recs {
+		values = append(values, rec)
+	}
+
+	return appendJSON(o.path, values...)
+}
+
+// appendJSON writes the values as JSON lines at the end of the file.
+func appendJSON(path string, values ...any) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	for _, v := range values {
+		line, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("could not encode outbox record: %v", err)
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("could not write %s: %v", path, err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// countLines returns the number of lines in the file, zero if it does not
+// exist.
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("could not open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var count int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("could not read %s: %v", path, err)
+	}
+
+	return count, nil
+}
+
+// compact rewrites the file with only the pending operations.
+func (o *Outbox) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), filepath.Base(o.path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not compact outbox: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	for _, op := range o.pending {
+		if err := enc.Encode(outboxRecord{Op: op}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("could not compact outbox: %v", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not compact outbox: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not compact outbox: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("could not compact outbox: %v", err)
+	}
+
+	return nil
+}
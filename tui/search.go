@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+type SearchResult struct {
+	Entries []domain.RatedEntry
+	Error   error
+}
+
+func (m model) search(q storage.SearchQuery) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := m.postgres.Search(q)
+		return SearchResult{
+			Entries: entries,
+			Error:   err,
+		}
+	}
+}
+
+// parseSearchQuery turns the input of the search prompt into a query. Words
+// with a known prefix are filters, the rest is the full text query:
+//
+//	rating:finished feed:lwn from:2025-01-01 to:2025-02-01 kernel
+func parseSearchQuery(input string, feeds map[int64]domain.Feed) (storage.SearchQuery, error) {
+	var q storage.SearchQuery
+	text := make([]string, 0)
+	for _, word := range strings.Fields(input) {
+		key, value, found := strings.Cut(word, ":")
+		if !found || value == "" {
+			text = append(text, word)
+			continue
+		}
+		switch key {
+		case "rating":
+			q.Rating = value
+		case "feed":
+			for id, f := range feeds {
+				if strings.Contains(strings.ToLower(f.Title), strings.ToLower(value)) {
+					q.FeedIDs = append(q.FeedIDs, id)
+				}
+			}
+			if len(q.FeedIDs) == 0 {
+				return storage.SearchQuery{}, fmt.Errorf("no feed matches %q", value)
+			}
+		case "from", "to":
+			date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+			if err != nil {
+				return storage.SearchQuery{}, fmt.Errorf("invalid date %q, use yyyy-mm-dd", value)
+			}
+			if key == "from" {
+				q.From = date
+			} else {
+				q.To = date.AddDate(0, 0, 1)
+			}
+		default:
+			text = append(text, word)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+
+	return q, nil
+}
+
+func (m model) updateSearchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		m.quitting = true
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.mode = modeList
+	case tea.KeyEnter:
+		q, err := parseSearchQuery(m.searchInput, m.feeds)
+		if err != nil {
+			m.status = fmt.Sprintf("Error: %s", err)
+			return m, nil
+		}
+		m.status = ""
+		return m, m.search(q)
+	case tea.KeyBackspace:
+		if len(m.searchInput) > 0 {
+			runes := []rune(m.searchInput)
+			m.searchInput = string(runes[:len(runes)-1])
+		}
+	case tea.KeySpace:
+		m.searchInput += " "
+	case tea.KeyRunes:
+		m.searchInput += string(msg.Runes)
+	}
+
+	return m, nil
+}
+
+func (m model) updateSearchResults(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.keys.Action(msg.String()) {
+	case actionQuit:
+		m.quitting = true
+		return m, tea.Quit
+	case actionBack:
+		m.mode = modeList
+		m.cursor = 0
+	case actionSearch:
+		m.mode = modeSearchInput
+	case actionUp:
+		if m.resultCursor > 0 {
+			m.resultCursor--
+		}
+	case actionDown:
+		if m.resultCursor < len(m.results)-1 {
+			m.resultCursor++
+		}
+	}
+
+	return m, nil
+}
+
+func (m model) searchListView() string {
+	s := fmt.Sprintf("Search: %s", m.searchInput)
+	if m.mode == modeSearchInput {
+		s += "_"
+	}
+	s += "\n"
+	if m.status != "" {
+		s += fmt.Sprintf("Status: %s\n", m.status)
+	}
+	s += fmt.Sprintf("%d results\n", len(m.results))
+
+	start := max(0, m.resultCursor-4)
+	for i := start; i < len(m.results) && i < start+5; i++ {
+		cursor := " "
+		if m.resultCursor == i {
+			cursor = ">"
+		}
+		r := m.results[i]
+		s += fmt.Sprintf("%s %s %-13s %s\n", cursor, r.Updated.Format(time.DateOnly), r.Rating, r.Entry.Title)
+	}
+
+	return s
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go-mod.ewintr.nl/algorithmic-rss/domain"
+)
+
+type SnoozedResult struct {
+	Entries []domain.SnoozedEntry
+	Error   error
+}
+
+func (m model) fetchSnoozed() tea.Cmd {
+	return func() tea.Msg {
+		entries, err := m.postgres.Snoozed()
+		return SnoozedResult{
+			Entries: entries,
+			Error:   err,
+		}
+	}
+}
+
+// rateSnoozed queues storing the rating and taking the entry out of the
+// snoozed entries. It was already marked read when it was snoozed.
+func (m model) rateSnoozed(entry domain.Entry, rating string) tea.Cmd {
+	return func() tea.Msg {
+		now := time.Now()
+		if err := m.outbox.Add(
+			&Op{
+				ID:      fmt.Sprintf("%s-%d-%d", opStore, entry.ID, now.UnixNano()),
+				Kind:    opStore,
+				EntryID: entry.ID,
+				Entry:   &entry,
+				Rating:  rating,
+				Created: now,
+			},
+			&Op{
+				ID:      fmt.Sprintf("%s-%d-%d", opUnsnooze, entry.ID, now.UnixNano()),
+				Kind:    opUnsnooze,
+				EntryID: entry.ID,
+				Created: now,
+			},
+		); err != nil {
+			return OutboxResult{Pending: m.outbox.Pending(), Failed: m.outbox.Failed(), Error: err}
+		}
+
+		return m.processOutbox()()
+	}
+}
+
+func (m model) updateSnoozed(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if rating, ok := m.keys.Rating(msg.String()); ok {
+		if len(m.snoozed) == 0 {
+			return m, nil
+		}
+		entry := m.snoozed[m.snoozedCursor].Entry
+		m.snoozed = append(m.snoozed[:m.snoozedCursor], m.snoozed[m.snoozedCursor+1:]...)
+		if m.snoozedCursor > 0 && m.snoozedCursor >= len(m.snoozed) {
+			m.snoozedCursor--
+		}
+		m.pending += 2
+		return m, m.rateSnoozed(entry, rating.Value)
+	}
+	switch m.keys.Action(msg.String()) {
+	case actionQuit:
+		m.quitting = true
+		return m, tea.Quit
+	case actionBack, actionSnoozed:
+		m.mode = modeList
+	case actionRefresh:
+		return m, m.fetchSnoozed()
+	case actionUp:
+		if m.snoozedCursor > 0 {
+			m.snoozedCursor--
+		}
+	case actionDown:
+		if m.snoozedCursor < len(m.snoozed)-1 {
+			m.snoozedCursor++
+		}
+	}
+
+	return m, nil
+}
+
+func (m model) snoozedListView() string {
+	s := fmt.Sprintf("Snoozed: %d entries\n", len(m.snoozed))
+	s += m.outboxView()
+	if m.status != "" {
+		s += fmt.Sprintf("Status: %s\n", m.status)
+	}
+	s += "\n"
+
+	start := max(0, m.snoozedCursor-4)
+	for i := start; i < len(m.snoozed) && i < start+5; i++ {
+		cursor := " "
+		if m.snoozedCursor == i {
+			cursor = ">"
+		}
+		se := m.snoozed[i]
+		s += fmt.Sprintf("%s %s %3.0f %s\n", cursor, se.Snoozed.Local().Format(time.DateOnly), se.Score*100, se.Entry.Title)
+	}
+
+	return s
+}
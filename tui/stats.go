@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"go-mod.ewintr.nl/algorithmic-rss/storage"
+)
+
+const (
+	sparklineDays = 30
+	statsFeeds    = 10
+)
+
+var (
+	sparkRunes   = []rune("▁▂▃▄▅▆▇█")
+	sectionStyle = lipgloss.NewStyle().Bold(true).MarginBottom(1)
+	cellStyle    = lipgloss.NewStyle().Padding(0, 1)
+)
+
+type StatsResult struct {
+	Matrix        map[int64]map[string]int64
+	Ratings       []string
+	CategoryNames map[int64]string
+	Feeds         []storage.FeedStat
+	Days          []storage.DayCount
+	DaysFrom      time.Time
+	Unread        map[int64]int
+	Error         error
+}
+
+func (m model) fetchStats() tea.Cmd {
+	return func() tea.Msg {
+		var res StatsResult
+		var err error
+		if res.Matrix, err = m.stats.CategoryRatingMatrix(); err != nil {
+			return StatsResult{Error: err}
+		}
+		if res.Ratings, err = m.stats.AllRatings(); err != nil {
+			return StatsResult{Error: err}
+		}
+		if res.CategoryNames, err = m.stats.CategoryNames(); err != nil {
+			return StatsResult{Error: err}
+		}
+		if res.Feeds, err = m.stats.FeedStats(); err != nil {
+			return StatsResult{Error: err}
+		}
+		now := time.Now()
+		res.DaysFrom = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -sparklineDays+1)
+		if res.Days, err = m.stats.RatingsPerDay(res.DaysFrom, time.Local); err != nil {
+			return StatsResult{Error: err}
+		}
+		if res.Unread, err = m.miniflux.UnreadCounts(); err != nil {
+			return StatsResult{Error: fmt.Errorf("could not fetch unread counts: %v", err)}
+		}
+
+		return res
+	}
+}
+
+func (m model) updateStats(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.keys.Action(msg.String()) {
+	case actionQuit:
+		m.quitting = true
+		return m, tea.Quit
+	case actionBack, actionStats:
+		m.mode = modeList
+	case actionRefresh:
+		return m, m.fetchStats()
+	}
+
+	return m, nil
+}
+
+func (m model) statsView() string {
+	if m.statsResult == nil {
+		return "loading statistics..."
+	}
+	res := m.statsResult
+
+	left := lipgloss.JoinVertical(lipgloss.Left,
+		sectionStyle.Render("Category × rating"),
+		m.matrixView(res),
+		"",
+		sectionStyle.Render("Unread backlog"),
+		m.unreadView(res),
+	)
+	right := lipgloss.JoinVertical(lipgloss.Left,
+		sectionStyle.Render("Finish rate per feed"),
+		m.feedRateView(res),
+	)
+	bottom := lipgloss.JoinVertical(lipgloss.Left,
+		sectionStyle.Render(fmt.Sprintf("Ratings per day, last %d days", sparklineDays)),
+		m.sparklineView(res),
+	)
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, left, "    ", right),
+		"",
+		bottom,
+	)
+}
+
+func (m model) categoryName(res *StatsResult, catID int64) string {
+	if title, ok := res.CategoryNames[catID]; ok {
+		return title
+	}
+	return fmt.Sprintf("%d", catID)
+}
+
+func (m model) matrixView(res *StatsResult) string {
+	catIDs := make([]int64, 0, len(res.Matrix))
+	for id := range res.Matrix {
+		catIDs = append(catIDs, id)
+	}
+	slices.Sort(catIDs)
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		StyleFunc(func(row, col int) lipgloss.Style { return cellStyle }).
+		Headers(append(append([]string{"category"}, res.Ratings...), "total")...)
+	for _, id := range catIDs {
+		row := []string{m.categoryName(res, id)}
+		var total int64
+		for _, rating := range res.Ratings {
+			row = append(row, fmt.Sprintf("%d", res.Matrix[id][rating]))
+			total += res.Matrix[id][rating]
+		}
+		t.Row(append(row, fmt.Sprintf("%d", total))...)
+	}
+
+	return t.Render()
+}
+
+func (m model) unreadView(res *StatsResult) string {
+	catIDs := make([]int64, 0, len(res.Unread))
+	for id := range res.Unread {
+		catIDs = append(catIDs, id)
+	}
+	slices.Sort(catIDs)
+
+	var s string
+	for _, id := range catIDs {
+		s += fmt.Sprintf("%-20s %5d\n", m.categoryName(res, id), res.Unread[id])
+	}
+
+	return s
+}
+
+func (m model) feedRateView(res *StatsResult) string {
+	feeds := slices.Clone(res.Feeds)
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return feeds[i].Rated > feeds[j].Rated
+	})
+	feeds = feeds[:min(statsFeeds, len(feeds))]
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		StyleFunc(func(row, col int) lipgloss.Style { return cellStyle }).
+		Headers("feed", "rated", "finished", "rate")
+	for _, f := range feeds {
+		t.Row(f.Title,
+			fmt.Sprintf("%d", f.Rated),
+			fmt.Sprintf("%d", f.Finished),
+			fmt.Sprintf("%3.0f%%", float64(f.Finished)/float64(f.Rated)*100))
+	}
+
+	return t.Render()
+}
+
+func (m model) sparklineView(res *StatsResult) string {
+	// days are matched by date, a day can be 23 or 25 hours around DST
+	days := make(map[string]int, sparklineDays)
+	for i := range sparklineDays {
+		days[res.DaysFrom.AddDate(0, 0, i).Format(time.DateOnly)] = i
+	}
+	perRating := make(map[string][]int64)
+	for _, dc := range res.Days {
+		idx, ok := days[dc.Day.Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+		if perRating[dc.Rating] == nil {
+			perRating[dc.Rating] = make([]int64, sparklineDays)
+		}
+		perRating[dc.Rating][idx] += dc.Count
+	}
+
+	var s string
+	for _, rating := range res.Ratings {
+		counts := perRating[rating]
+		if counts == nil {
+			counts = make([]int64, sparklineDays)
+		}
+		var total int64
+		for _, c := range counts {
+			total += c
+		}
+		s += fmt.Sprintf("%-14s %s %5d\n", rating, sparkline(counts), total)
+	}
+
+	return s
+}
+
+func sparkline(counts []int64) string {
+	maxCount := slices.Max(counts)
+	var sb strings.Builder
+	for _, c := range counts {
+		if c == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		idx := int(float64(c) / float64(maxCount) * float64(len(sparkRunes)-1))
+		sb.WriteRune(sparkRunes[idx])
+	}
+
+	return sb.String()
+}
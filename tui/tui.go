@@ -3,9 +3,13 @@ package main
 import (
 	"fmt"
 	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"go-mod.ewintr.nl/algorithmic-rss/domain"
+	"go-mod.ewintr.nl/algorithmic-rss/scoring"
+	"go-mod.ewintr.nl/algorithmic-rss/source"
 	"go-mod.ewintr.nl/algorithmic-rss/storage"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -26,9 +30,17 @@ type FeedsResult struct {
 type EntriesResult struct {
 	CategoryID int64
 	Entries    []domain.Entry
+	Edition    *domain.Edition
+	Queued     int
 	Error      error
 }
 
+type ModelResult struct {
+	Model *scoring.Model
+	Rules scoring.Rules
+	Error error
+}
+
 func (m model) fetchCategories() tea.Cmd {
 	return func() tea.Msg {
 		cats, err := m.postgres.Categories()
@@ -66,53 +78,168 @@ func (m model) fetchFeeds() tea.Cmd {
 	}
 }
 
+// fetchUnread gets the unread entries of the current edition. Unread entries
+// that are not in it arrived after it was made and are only counted.
 func (m model) fetchUnread(categoryID int64) tea.Cmd {
 	return func() tea.Msg {
 		entries, err := m.miniflux.Unread(categoryID)
-		return EntriesResult{
+		if err != nil {
+			return EntriesResult{CategoryID: categoryID, Error: err}
+		}
+		if entries, err = m.postgres.WithExtracted(entries); err != nil {
+			return EntriesResult{CategoryID: categoryID, Error: err}
+		}
+		edition, err := m.postgres.CurrentEdition(categoryID)
+		if err != nil {
+			return EntriesResult{CategoryID: categoryID, Error: err}
+		}
+		result := EntriesResult{
 			CategoryID: categoryID,
 			Entries:    entries,
-			Error:      err,
+			Edition:    edition,
 		}
+		if edition != nil {
+			result.Entries = slices.DeleteFunc(entries, func(e domain.Entry) bool {
+				return !slices.Contains(edition.EntryIDs, e.ID)
+			})
+			result.Queued = len(entries) - len(result.Entries)
+		}
+
+		return result
 	}
 }
 
-type MarkReadResult error
-
-func (m model) rateEntry(entry domain.Entry, rate string) tea.Cmd {
+func (m model) fetchModel() tea.Cmd {
 	return func() tea.Msg {
-		var rateStr string
-		switch rate {
-		case "1":
-			rateStr = "not_opened"
-		case "2":
-			rateStr = "only_comments"
-		case "3":
-			rateStr = "not_finished"
-		case "4":
-			rateStr = "finished"
-		default:
-			return MarkReadResult(fmt.Errorf("unknown rating"))
+		rated, err := m.postgres.RatedEntries()
+		if err != nil {
+			return ModelResult{Error: err}
 		}
-		if err := m.postgres.StoreEntry(entry, rateStr); err != nil {
-			return MarkReadResult(fmt.Errorf("could not store entry: %v", err))
+		stored, err := m.postgres.Rules()
+		if err != nil {
+			return ModelResult{Error: err}
 		}
-		if err := m.miniflux.MarkRead(entry.ID); err != nil {
-			return MarkReadResult(fmt.Errorf("could not mark entry read: %v", err))
+		return ModelResult{Model: scoring.Train(rated), Rules: scoring.DefaultRules.With(stored)}
+	}
+}
+
+type OutboxResult struct {
+	Pending int
+	Failed  int
+	Error   error
+}
+
+type OutboxTick struct{}
+
+const outboxInterval = 5 * time.Second
+
+// rateEntry queues storing the rating and marking the entry read in the
+// outbox and starts processing it.
+func (m model) rateEntry(entry domain.Entry, rating string) tea.Cmd {
+	return func() tea.Msg {
+		now := time.Now()
+		if err := m.outbox.Add(
+			&Op{
+				ID:      fmt.Sprintf("%s-%d-%d", opStore, entry.ID, now.UnixNano()),
+				Kind:    opStore,
+				EntryID: entry.ID,
+				Entry:   &entry,
+				Rating:  rating,
+				Created: now,
+			},
+			&Op{
+				ID:      fmt.Sprintf("%s-%d-%d", opMarkRead, entry.ID, now.UnixNano()),
+				Kind:    opMarkRead,
+				EntryID: entry.ID,
+				Created: now,
+			},
+		); err != nil {
+			return OutboxResult{Pending: m.outbox.Pending(), Failed: m.outbox.Failed(), Error: err}
 		}
 
-		return MarkReadResult(nil)
+		return m.processOutbox()()
 	}
 }
 
+func (m model) processOutbox() tea.Cmd {
+	return func() tea.Msg {
+		err := m.outbox.Process(time.Now(), func(op *Op) error {
+			switch op.Kind {
+			case opStore:
+				if err := m.postgres.StoreEntry(*op.Entry, op.Rating, op.Created); err != nil {
+					return fmt.Errorf("could not store entry: %v", err)
+				}
+			case opMarkRead:
+				if err := m.miniflux.MarkRead(op.EntryID); err != nil {
+					return fmt.Errorf("could not mark entry read: %v", err)
+				}
+			case opUnsnooze:
+				if err := m.postgres.Unsnooze(op.EntryID); err != nil {
+					return fmt.Errorf("could not unsnooze entry: %v", err)
+				}
+			}
+			return nil
+		})
+
+		return OutboxResult{Pending: m.outbox.Pending(), Failed: m.outbox.Failed(), Error: err}
+	}
+}
+
+// outboxView shows the operations that still have to be applied and the ones
+// that were given up on.
+func (m model) outboxView() string {
+	var s string
+	if m.pending > 0 {
+		s += fmt.Sprintf("Pending: %d operations\n", m.pending)
+	}
+	if m.failed > 0 {
+		s += fmt.Sprintf("Failed: %d operations, see %s\n", m.failed, m.outbox.FailedPath())
+	}
+
+	return s
+}
+
+func outboxTick() tea.Cmd {
+	return tea.Tick(outboxInterval, func(time.Time) tea.Msg {
+		return OutboxTick{}
+	})
+}
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeSearchInput
+	modeSearchResults
+	modeStats
+	modeSnoozed
+)
+
 type model struct {
-	miniflux        *Miniflux
+	miniflux        *source.Miniflux
 	postgres        *storage.TuiRepo
+	stats           *storage.CliRepo
+	outbox          *Outbox
+	pending         int
+	failed          int
+	keys            KeyMap
+	statsResult     *StatsResult
 	lastUpdate      time.Time
 	categories      map[int64]domain.Category
 	feeds           map[int64]domain.Feed
 	entries         map[int64][]domain.Entry
+	editions        map[int64]*domain.Edition
+	queued          map[int64]int
+	scorer          scoring.Scorer
+	scores          map[int64]scoring.Explanation
+	sortByScore     bool
 	currentCategory int64
+	mode            mode
+	searchInput     string
+	results         []domain.RatedEntry
+	resultCursor    int
+	snoozed         []domain.SnoozedEntry
+	snoozedCursor   int
 	status          string
 	cursor          int
 	width           int
@@ -120,16 +247,26 @@ type model struct {
 	quitting        bool
 }
 
-func InitialModel(mf *Miniflux, repo *storage.TuiRepo) model {
+func InitialModel(mf *source.Miniflux, repo *storage.TuiRepo, stats *storage.CliRepo, outbox *Outbox, keys KeyMap, sortByScore bool) model {
 	return model{
 		miniflux:   mf,
 		postgres:   repo,
+		stats:      stats,
+		outbox:     outbox,
+		pending:    outbox.Pending(),
+		failed:     outbox.Failed(),
+		keys:       keys,
 		categories: make(map[int64]domain.Category, 0),
 		feeds:      make(map[int64]domain.Feed, 0),
 		entries: map[int64][]domain.Entry{
 			domain.CatNewsAggregator: make([]domain.Entry, 0),
 			domain.Personal:          make([]domain.Entry, 0),
 		},
+		editions:        make(map[int64]*domain.Edition),
+		queued:          make(map[int64]int),
+		scorer:          scoring.Scorer{Rules: scoring.DefaultRules},
+		scores:          make(map[int64]scoring.Explanation),
+		sortByScore:     sortByScore,
 		currentCategory: domain.Personal,
 	}
 }
@@ -140,6 +277,9 @@ func (m model) Init() tea.Cmd {
 		m.fetchFeeds(),
 		m.fetchUnread(domain.CatNewsAggregator),
 		m.fetchUnread(domain.Personal),
+		m.fetchModel(),
+		m.processOutbox(),
+		outboxTick(),
 	)
 }
 
@@ -168,6 +308,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			feeds[f.ID] = f
 		}
 		m.feeds = feeds
+		m.updateScores()
 
 	case EntriesResult:
 		if msg.Error != nil {
@@ -182,38 +323,106 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			entries = append(entries, e)
 		}
 		m.entries[msg.CategoryID] = entries
+		m.editions[msg.CategoryID] = msg.Edition
+		m.queued[msg.CategoryID] = msg.Queued
+		m.updateScores()
 		// m.status = fmt.Sprintf("Fetched %d entries.", len(m.entries))
 		m.lastUpdate = time.Now()
-	case MarkReadResult:
-		if msg != nil {
-			m.status = fmt.Sprintf("Error: %s", error(msg))
+	case ModelResult:
+		if msg.Error != nil {
+			m.status = fmt.Sprintf("Error: %s", msg.Error)
+			return m, nil
+		}
+		m.scorer.Model = msg.Model
+		m.scorer.Rules = msg.Rules
+		m.updateScores()
+	case SearchResult:
+		if msg.Error != nil {
+			m.status = fmt.Sprintf("Error: %s", msg.Error)
+			return m, nil
+		}
+		m.results = msg.Entries
+		m.resultCursor = 0
+		m.mode = modeSearchResults
+	case SnoozedResult:
+		if msg.Error != nil {
+			m.status = fmt.Sprintf("Error: %s", msg.Error)
+			return m, nil
 		}
+		m.snoozed = msg.Entries
+		m.snoozedCursor = 0
+	case StatsResult:
+		if msg.Error != nil {
+			m.status = fmt.Sprintf("Error: %s", msg.Error)
+			m.mode = modeList
+			return m, nil
+		}
+		m.statsResult = &msg
+	case OutboxResult:
+		m.pending = msg.Pending
+		m.failed = msg.Failed
+		if msg.Error != nil {
+			m.status = fmt.Sprintf("Error: %s", msg.Error)
+		}
+	case OutboxTick:
+		return m, tea.Batch(m.processOutbox(), outboxTick())
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		switch m.mode {
+		case modeSearchInput:
+			return m.updateSearchInput(msg)
+		case modeSearchResults:
+			return m.updateSearchResults(msg)
+		case modeStats:
+			return m.updateStats(msg)
+		case modeSnoozed:
+			return m.updateSnoozed(msg)
+		}
+		if rating, ok := m.keys.Rating(msg.String()); ok {
+			if len(m.entries[m.currentCategory]) == 0 {
+				return m, nil
+			}
+			entry := m.entries[m.currentCategory][m.cursor]
+			m.entries[m.currentCategory] = append(m.entries[m.currentCategory][:m.cursor], m.entries[m.currentCategory][m.cursor+1:]...)
+			if m.cursor > 0 && m.cursor >= len(m.entries[m.currentCategory]) {
+				m.cursor--
+			}
+			m.pending += 2
+			return m, m.rateEntry(entry, rating.Value)
+		}
+		switch m.keys.Action(msg.String()) {
+		case actionQuit:
 			m.quitting = true
 			return m, tea.Quit
-		case "r":
+		case actionRefresh:
 			return m, m.fetchUnread(m.currentCategory)
-		case "left", "right":
+		case actionSort:
+			m.sortByScore = !m.sortByScore
+			m.sortEntries()
+		case actionSearch:
+			m.mode = modeSearchInput
+			m.status = ""
+		case actionStats:
+			m.mode = modeStats
+			return m, m.fetchStats()
+		case actionSnoozed:
+			m.mode = modeSnoozed
+			m.status = ""
+			return m, m.fetchSnoozed()
+		case actionCategory:
 			if m.currentCategory == domain.Personal {
 				m.currentCategory = domain.CatNewsAggregator
 				return m, nil
 			}
 			m.currentCategory = domain.Personal
 			return m, nil
-		case "up":
+		case actionUp:
 			if m.cursor > 0 {
 				m.cursor--
 			}
-		case "down":
+		case actionDown:
 			if m.cursor < 4 && m.cursor < len(m.entries[m.currentCategory])-1 {
 				m.cursor++
 			}
-		case "1", "2", "3", "4":
-			entry := m.entries[m.currentCategory][m.cursor]
-			m.entries[m.currentCategory] = append(m.entries[m.currentCategory][:m.cursor], m.entries[m.currentCategory][m.cursor+1:]...)
-			return m, m.rateEntry(entry, msg.String())
 		}
 	}
 
@@ -224,13 +433,6 @@ func (m model) View() string {
 	if m.width == 0 {
 		return "loading..."
 	}
-	list := lipgloss.NewStyle().
-		Width(m.width).
-		Height(9).
-		Border(lipgloss.NormalBorder(), false, false, true, false).
-		Padding(1, 2).
-		Render(m.listView())
-
 	help := lipgloss.NewStyle().
 		Width(m.width).
 		Height(2).
@@ -238,6 +440,23 @@ func (m model) View() string {
 		Padding(1, 2).
 		Render(m.helpView())
 
+	if m.mode == modeStats {
+		stats := lipgloss.NewStyle().
+			Width(m.width).
+			Height(m.height-lipgloss.Height(help)).
+			MaxHeight(m.height-lipgloss.Height(help)).
+			Padding(1, 2).
+			Render(m.statsView())
+		return lipgloss.JoinVertical(lipgloss.Top, stats, help)
+	}
+
+	list := lipgloss.NewStyle().
+		Width(m.width).
+		Height(9).
+		Border(lipgloss.NormalBorder(), false, false, true, false).
+		Padding(1, 2).
+		Render(m.listView())
+
 	entry := lipgloss.NewStyle().
 		Width(m.width).
 		Height(m.height-lipgloss.Height(list)-lipgloss.Height(help)).
@@ -249,7 +468,15 @@ func (m model) View() string {
 }
 
 func (m model) listView() string {
+	switch m.mode {
+	case modeSnoozed:
+		return m.snoozedListView()
+	case modeSearchInput, modeSearchResults:
+		return m.searchListView()
+	}
 	s := fmt.Sprintf("Total unread aggregator: %d, personal %d\n", len(m.entries[domain.CatNewsAggregator]), len(m.entries[domain.Personal]))
+	s += m.editionView() + "\n"
+	s += m.outboxView()
 	if m.status != "" {
 		s += fmt.Sprintf("Status: %s\n", m.status)
 	}
@@ -259,7 +486,26 @@ func (m model) listView() string {
 		if m.cursor == i {
 			cursor = ">"
 		}
-		s += fmt.Sprintf("%s %s\n", cursor, m.entries[m.currentCategory][i].Title)
+		entry := m.entries[m.currentCategory][i]
+		s += fmt.Sprintf("%s %s %s\n", cursor, m.scoreLabel(entry.ID), entry.Title)
+	}
+
+	return s
+}
+
+func (m model) editionView() string {
+	ed := m.editions[m.currentCategory]
+	if ed == nil {
+		return "No edition yet, showing all unread entries"
+	}
+	s := fmt.Sprintf("Edition %d of %s", ed.ID, ed.Created.Local().Format("Mon 15:04"))
+	if time.Now().Before(ed.ValidUntil) {
+		s += fmt.Sprintf(", next at %s", ed.ValidUntil.Local().Format("Mon 15:04"))
+	} else {
+		s += ", next is due"
+	}
+	if q := m.queued[m.currentCategory]; q > 0 {
+		s += fmt.Sprintf(", %d new entries queued", q)
 	}
 
 	return s
@@ -267,24 +513,55 @@ func (m model) listView() string {
 
 func (m model) entryView() string {
 	var s string
-	if len(m.entries[m.currentCategory]) > 0 {
+	switch {
+	case (m.mode == modeSearchInput || m.mode == modeSearchResults) && len(m.results) > 0:
+		selected := m.results[m.resultCursor]
+		s += fmt.Sprintf("Feed: %s\n", m.feeds[selected.Entry.FeedID].Title)
+		s += fmt.Sprintf("Title: %s\n", selected.Entry.Title)
+		s += fmt.Sprintf("URL: %s\n", selected.Entry.URL)
+		s += fmt.Sprintf("Rating: %s (%s)\n", selected.Rating, selected.Updated.Format(time.DateOnly))
+		s += m.contentView(selected.Entry)
+	case m.mode == modeSnoozed && len(m.snoozed) > 0:
+		selected := m.snoozed[m.snoozedCursor]
+		s += fmt.Sprintf("Feed: %s\n", m.feeds[selected.Entry.FeedID].Title)
+		s += fmt.Sprintf("Title: %s\n", selected.Entry.Title)
+		s += fmt.Sprintf("URL: %s\n", selected.Entry.URL)
+		s += fmt.Sprintf("Snoozed: %s, score %.0f\n", selected.Snoozed.Local().Format(time.DateOnly), selected.Score*100)
+		s += m.contentView(selected.Entry)
+	case m.mode == modeList && len(m.entries[m.currentCategory]) > 0:
 		selected := m.entries[m.currentCategory][m.cursor]
 		s += fmt.Sprintf("Feed: %s\n", m.feeds[selected.FeedID].Title)
 		s += fmt.Sprintf("Title: %s\n", selected.Title)
-		s += fmt.Sprintf("URL: %s\n\n", selected.URL)
-		content, err := glamour.Render(selected.Content, "dark")
-		if err != nil {
-			content = fmt.Sprintf("could not render body: %v", content)
-		}
-		s += fmt.Sprintf("\n%s\n", content)
+		s += fmt.Sprintf("URL: %s\n", selected.URL)
+		s += m.explanationView(selected.ID)
+		s += m.contentView(selected)
 	}
 
 	return s
 }
 
+func (m model) contentView(entry domain.Entry) string {
+	content, err := glamour.Render(entry.Content, "dark")
+	if err != nil {
+		content = fmt.Sprintf("could not render body: %v", content)
+	}
+
+	return fmt.Sprintf("\n\n%s\n", content)
+}
+
 func (m model) helpView() string {
-	s := "Rate: 1: Not opened, 2: Only comments, 3: Not finished, 4: Finished\n\n"
-	s += "Press left or right arrows to change category, r to refresh, q to quit.\n"
+	switch m.mode {
+	case modeSearchInput:
+		return "Filters: rating:<rating> feed:<title> from:<yyyy-mm-dd> to:<yyyy-mm-dd>\n\nenter: search, esc: back\n"
+	case modeSearchResults:
+		return m.keys.Help(actionUp, actionDown, actionSearch, actionBack, actionQuit) + "\n"
+	case modeStats:
+		return m.keys.Help(actionRefresh, actionStats, actionBack, actionQuit) + "\n"
+	case modeSnoozed:
+		return m.keys.RatingHelp() + "\n\n" + m.keys.Help(actionUp, actionDown, actionRefresh, actionBack, actionQuit) + "\n"
+	}
+	s := m.keys.RatingHelp() + "\n\n"
+	s += m.keys.Help(actionUp, actionDown, actionCategory, actionRefresh, actionSort, actionSearch, actionStats, actionSnoozed, actionQuit) + "\n"
 
 	return s
 }
@@ -297,3 +574,61 @@ func (m model) isVideo(feedID int64) bool {
 	}
 	return false
 }
+
+// updateScores scores all fetched entries with the current model and
+// reapplies the sort order.
+func (m *model) updateScores() {
+	now := time.Now()
+	scores := make(map[int64]scoring.Explanation)
+	for _, entries := range m.entries {
+		for _, e := range entries {
+			scores[e.ID] = m.scorer.Score(e, m.feeds[e.FeedID].CategoryID, now)
+		}
+	}
+	m.scores = scores
+	m.sortEntries()
+}
+
+func (m *model) sortEntries() {
+	for _, entries := range m.entries {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if m.sortByScore {
+				return m.scores[entries[i].ID].Score > m.scores[entries[j].ID].Score
+			}
+			return entries[i].Published.Before(entries[j].Published)
+		})
+	}
+}
+
+func (m model) scoreLabel(entryID int64) string {
+	exp, ok := m.scores[entryID]
+	switch {
+	case !ok || m.scorer.Model == nil:
+		return "[  ?]"
+	case exp.Skip:
+		return "[ --]"
+	default:
+		return fmt.Sprintf("[%3.0f]", exp.Score*100)
+	}
+}
+
+func (m model) explanationView(entryID int64) string {
+	exp, ok := m.scores[entryID]
+	if !ok || m.scorer.Model == nil {
+		return "Score: not available\n"
+	}
+
+	s := fmt.Sprintf("Score: %.0f (feed prior %.0f, trained on %d ratings)\n", exp.Score*100, exp.FeedPrior*100, m.scorer.Model.Size())
+	if len(exp.Rules) > 0 {
+		s += fmt.Sprintf("Rules: %s\n", strings.Join(exp.Rules, ", "))
+	}
+	if len(exp.Terms) > 0 {
+		terms := make([]string, 0, len(exp.Terms))
+		for _, t := range exp.Terms {
+			terms = append(terms, fmt.Sprintf("%s %+.2f", t.Term, t.Weight))
+		}
+		s += fmt.Sprintf("Terms: %s\n", strings.Join(terms, ", "))
+	}
+
+	return s
+}